@@ -0,0 +1,153 @@
+package silent
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrAADMismatch     = errors.New("associated data mismatch")
+	ErrAADNotSupported = errors.New("crypter does not support associated data")
+)
+
+// AADCrypter is an optional extension of [Crypter] for crypters that can bind encrypted data
+// to additional authenticated data (AAD), such as a primary key of the row the data belongs to.
+// AAD is not stored in the encrypted data, and exactly the same AAD must be provided on decryption.
+// Otherwise, decryption fails with an authentication error.
+type AADCrypter interface {
+	Crypter
+	EncryptWithAAD(data, aad []byte) ([]byte, error)
+	DecryptWithAAD(data, aad []byte) ([]byte, error)
+}
+
+// EncryptWithAAD is like [Encrypt], but binds the encrypted data to aad. See [AADCrypter].
+// Empty aad is the same as no aad at all, so the result is compatible with [Decrypt].
+func (s *MultiKeyCrypter) EncryptWithAAD(data, aad []byte) ([]byte, error) {
+	return s.encrypt(data, aad)
+}
+
+// DecryptWithAAD is like [Decrypt], but verifies that the data was encrypted with exactly the same aad.
+// On mismatch, a [DecryptError] of kind [KindAuthFailed] is returned.
+// Data encrypted without aad is rejected as well, unless aad is empty.
+func (s *MultiKeyCrypter) DecryptWithAAD(data, aad []byte) ([]byte, error) {
+	return s.decrypt(data, aad)
+}
+
+// deriveAADKey derives a subkey bound to aad. Since sio doesn't support associated data,
+// the binding is done by encrypting the data with this subkey instead of the key itself.
+func deriveAADKey(key, aad []byte) ([]byte, error) {
+	info := make([]byte, 0, len("silent aad ")+len(aad))
+	info = append(info, "silent aad "...)
+	info = append(info, aad...)
+
+	res := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, info), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// AADValue is an encrypted value bound to associated data, typically the primary key of the row it's stored in.
+// This prevents an attacker with write access to the database from moving encrypted values between rows:
+// such values fail to decrypt with an authentication error.
+//
+// The crypter bound to the value type must implement [AADCrypter].
+// The AAD must be set before scanning, and must match the one used on write exactly, byte for byte:
+//
+//	v := silent.WithAAD[silent.EncryptedValue](nil, []byte(userID))
+//	err := row.Scan(&v)
+type AADValue[T any] struct {
+	Data EncryptedValueFactory[T]
+	AAD  []byte
+}
+
+// WithAAD creates a new [AADValue] from the value and associated data.
+func WithAAD[F EncryptedValueFactory[T], T any](v F, aad []byte) AADValue[T] {
+	return AADValue[T]{Data: EncryptedValueFactory[T](v), AAD: aad}
+}
+
+// Value is a driver.Valuer implementation. It encrypts the value bound to AAD.
+func (v AADValue[T]) Value() (driver.Value, error) {
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v.Data) == 0 {
+		return crypter.driverValue([]byte{}), nil
+	}
+
+	encData, err := crypter.EncryptWithAAD(v.Data, v.AAD)
+	if err != nil {
+		return nil, err
+	}
+	return crypter.driverValue(encData), nil
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the value and verifies it's bound to AAD.
+func (v *AADValue[T]) Scan(value interface{}) error {
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	encData, ok := scanBytes(value)
+	if !ok {
+		return fmt.Errorf("unable to scan %T into AADValue", value)
+	}
+
+	if len(encData) == 0 {
+		v.Data = nil
+		return nil
+	}
+
+	encData, err = crypter.scannedData(encData)
+	if err != nil {
+		return err
+	}
+
+	data, err := crypter.DecryptWithAAD(encData, v.AAD)
+	if err != nil {
+		return err
+	}
+
+	v.Data = data
+	return nil
+}
+
+// ValueWithAAD is like the Value method of v, but binds the encrypted data to aad. It's a shortcut for [WithAAD]
+// followed by Value, meant to be called explicitly in the repository layer, since driver.Valuer doesn't get any context.
+// A typical pattern is to bind values to the primary key of their row:
+//
+//	token, err := silent.ValueWithAAD(user.Token, []byte(user.ID))
+//	...
+//	_, err = db.ExecContext(ctx, "INSERT INTO users (id, token) VALUES (?, ?)", user.ID, token)
+//
+// The primary key must be known before the insert, so auto-generated keys need to be allocated first.
+func ValueWithAAD[F EncryptedValueFactory[T], T any](v F, aad []byte) (driver.Value, error) {
+	return WithAAD[F, T](v, aad).Value()
+}
+
+// ScanWithAAD is the counterpart of [ValueWithAAD]. It decrypts src, which is a value read from the database,
+// into dst, and verifies that it's bound to aad. To get the primary key first, scan the encrypted column into
+// an intermediate variable:
+//
+//	var id string
+//	var token []byte
+//	err := db.QueryRowContext(ctx, "SELECT id, token FROM users WHERE ...").Scan(&id, &token)
+//	...
+//	err = silent.ScanWithAAD(&user.Token, token, []byte(id))
+func ScanWithAAD[F EncryptedValueFactory[T], T any](dst *F, src interface{}, aad []byte) error {
+	v := AADValue[T]{AAD: aad}
+	if err := v.Scan(src); err != nil {
+		return err
+	}
+
+	*dst = F(v.Data)
+	return nil
+}
@@ -0,0 +1,147 @@
+package silent
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestAAD(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	text := []byte("Hello, World!")
+
+	requireAuthFailed := func(t *testing.T, err error) {
+		t.Helper()
+		var decErr *DecryptError
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+	}
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		enc, err := c1.EncryptWithAAD(text, []byte("row 1"))
+		RequireNoError(t, err)
+		RequireEqual(t, enc[0], byte(2))
+
+		keyID, err := c1.KeyIDOf(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(1))
+
+		dec, err := c1.DecryptWithAAD(enc, []byte("row 1"))
+		RequireNoError(t, err)
+		RequireEqual(t, dec, text)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		enc, err := c1.EncryptWithAAD(text, []byte("row 1"))
+		RequireNoError(t, err)
+
+		_, err = c1.DecryptWithAAD(enc, []byte("row 2"))
+		requireAuthFailed(t, err)
+
+		_, err = c1.Decrypt(enc)
+		requireAuthFailed(t, err)
+	})
+
+	t.Run("unbound data", func(t *testing.T) {
+		enc, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		_, err = c1.DecryptWithAAD(enc, []byte("row 1"))
+		requireAuthFailed(t, err)
+		RequireErrorIs(t, err, ErrAADMismatch)
+
+		// empty aad is the same as no aad
+		dec, err := c1.DecryptWithAAD(enc, nil)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, text)
+
+		enc, err = c1.EncryptWithAAD(text, nil)
+		RequireNoError(t, err)
+
+		dec, err = c1.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, text)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		type dummy1 struct{}
+		type EncryptedValue1 = EncryptedValueFactory[dummy1]
+		BindCrypterTo[EncryptedValue1](&c1, WithPepper([]byte("pepper")))
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+		enc, err := WithAAD(EncryptedValue1("Hello, World!"), []byte("row 1")).Value()
+		RequireNoError(t, err)
+
+		v := WithAAD[EncryptedValue1](nil, []byte("row 1"))
+		RequireNoError(t, v.Scan(enc))
+		RequireEqual(t, string(v.Data), "Hello, World!")
+
+		v = WithAAD[EncryptedValue1](nil, []byte("row 2"))
+		requireAuthFailed(t, v.Scan(enc))
+
+		v = WithAAD[EncryptedValue1](nil, []byte("row 2"))
+		RequireNoError(t, v.Scan(nil))
+		RequireEqual(t, len(v.Data), 0)
+	})
+
+	t.Run("sql helpers", func(t *testing.T) {
+		type dummy3 struct{}
+		type EncryptedValue3 = EncryptedValueFactory[dummy3]
+		BindCrypterTo[EncryptedValue3](&c1)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue3]() })
+
+		db, err := sql.Open("ramsql", "TestAADSQLHelpers")
+		RequireNoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		// no primary key: ramsql index lookups fail checkptr validation under -race
+		_, err = db.Exec("CREATE TABLE users (id BIGINT, token VARBINARY(255))")
+		RequireNoError(t, err)
+
+		for id, token := range []string{"token 0", "token 1"} {
+			v, err := ValueWithAAD(EncryptedValue3(token), []byte(strconv.Itoa(id)))
+			RequireNoError(t, err)
+
+			_, err = db.Exec("INSERT INTO users (id, token) VALUES (?, ?)", id, v)
+			RequireNoError(t, err)
+		}
+
+		read := func(id int) (int, []byte) {
+			var pk int
+			var raw []byte
+			RequireNoError(t, db.QueryRow("SELECT id, token FROM users WHERE id = ?", id).Scan(&pk, &raw))
+			return pk, raw
+		}
+
+		pk, raw := read(1)
+		var token EncryptedValue3
+		RequireNoError(t, ScanWithAAD(&token, raw, []byte(strconv.Itoa(pk))))
+		RequireEqual(t, string(token), "token 1")
+
+		// a value moved to another row fails to decrypt
+		_, raw0 := read(0)
+		requireAuthFailed(t, ScanWithAAD(&token, raw0, []byte(strconv.Itoa(pk))))
+
+		// without aad, it fails as well
+		requireAuthFailed(t, token.Scan(raw))
+
+		// empty values
+		v, err := ValueWithAAD(EncryptedValue3(""), []byte("2"))
+		RequireNoError(t, err)
+		RequireNoError(t, ScanWithAAD(&token, v, []byte("2")))
+		RequireEqual(t, len(token), 0)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		type dummy2 struct{}
+		type EncryptedValue2 = EncryptedValueFactory[dummy2]
+		BindCrypterTo[EncryptedValue2](NewEpochCrypter(DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), 1))
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue2]() })
+
+		_, err := WithAAD(EncryptedValue2("Hello, World!"), []byte("row 1")).Value()
+		RequireErrorIs(t, err, ErrAADNotSupported)
+	})
+}
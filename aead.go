@@ -0,0 +1,119 @@
+package silent
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+const aeadHeaderSize = 5 // version + key id
+
+// aeadKeyring implements multi-key encryption on top of an AEAD with random nonces.
+// The encrypted data consists of a version byte, a little-endian key ID, a nonce and the sealed data.
+// The version byte and the key ID are authenticated as associated data, followed by the optional
+// associated data passed to encrypt and decrypt.
+type aeadKeyring struct {
+	aeads     map[uint32]cipher.AEAD
+	lastKeyID uint32
+}
+
+func (k *aeadKeyring) addKey(keyID uint32, key []byte, newAEAD func(key []byte) (cipher.AEAD, error)) {
+	if k.aeads == nil {
+		k.aeads = make(map[uint32]cipher.AEAD)
+	}
+
+	if len(key) != 32 {
+		panic("misconfiguration: key must be exactly 32 bytes")
+	}
+
+	if _, ok := k.aeads[keyID]; ok {
+		panic("misconfiguration: all key ids must be unique")
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		panic(err) // can't happen for 32 bytes keys
+	}
+
+	k.aeads[keyID] = aead
+	k.lastKeyID = keyID
+}
+
+// encrypt encrypts the data with the last added key. The nonce is read from random, or from crypto/rand if it's nil.
+// The same ad must be passed to decrypt.
+func (k *aeadKeyring) encrypt(data []byte, random io.Reader, ad []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	aead := k.aeads[k.lastKeyID]
+	if aead == nil {
+		panic("misconfiguration: no keys were added")
+	}
+
+	nonceSize := aead.NonceSize()
+	res := make([]byte, aeadHeaderSize+nonceSize, aeadHeaderSize+nonceSize+len(data)+aead.Overhead())
+	res[0] = 1
+	binary.LittleEndian.PutUint32(res[1:aeadHeaderSize], k.lastKeyID)
+
+	nonce := res[aeadHeaderSize:]
+	if _, err := io.ReadFull(randReader(random), nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(res, nonce, data, associatedData(res[:aeadHeaderSize], ad)), nil
+}
+
+// randReader returns r, or crypto/rand.Reader if r is nil.
+func randReader(r io.Reader) io.Reader {
+	if r == nil {
+		return rand.Reader
+	}
+	return r
+}
+
+// associatedData returns the header followed by ad.
+func associatedData(header, ad []byte) []byte {
+	if len(ad) == 0 {
+		return header
+	}
+
+	res := make([]byte, 0, len(header)+len(ad))
+	res = append(res, header...)
+	return append(res, ad...)
+}
+
+func (k *aeadKeyring) decrypt(data []byte, ad []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	version := data[0]
+	if version != 1 {
+		return nil, &DecryptError{Kind: KindUnsupportedVersion, Version: version, Cause: ErrUnsupportedVersion}
+	}
+
+	if len(data) < aeadHeaderSize {
+		return nil, &DecryptError{Kind: KindCorrupt, Version: version, Cause: truncatedError(io.ErrUnexpectedEOF)}
+	}
+
+	keyID := binary.LittleEndian.Uint32(data[1:aeadHeaderSize])
+	aead := k.aeads[keyID]
+	if aead == nil {
+		return nil, &DecryptError{Kind: KindUnknownKey, Version: version, KeyID: keyID, Cause: ErrUnknownKey}
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(data) < aeadHeaderSize+nonceSize+aead.Overhead() {
+		return nil, &DecryptError{Kind: KindCorrupt, Version: version, KeyID: keyID, Cause: truncatedError(io.ErrUnexpectedEOF)}
+	}
+
+	nonce := data[aeadHeaderSize : aeadHeaderSize+nonceSize]
+	res, err := aead.Open(nil, nonce, data[aeadHeaderSize+nonceSize:], associatedData(data[:aeadHeaderSize], ad))
+	if err != nil {
+		return nil, &DecryptError{Kind: KindAuthFailed, Version: version, KeyID: keyID, Cause: err}
+	}
+
+	return res, nil
+}
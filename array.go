@@ -0,0 +1,199 @@
+package silent
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidArray = errors.New("invalid array literal")
+
+// EncryptedValueArrayFactory is an array of encrypted values, stored in a PostgreSQL bytea[] column,
+// or in a text[] column if the bound crypter uses [WithTextEncoding]. Each element is encrypted separately,
+// using the crypter bound to EncryptedValueFactory[T].
+//
+// Nil elements are stored as NULL, while empty non-nil elements are stored as empty values,
+// and both are read back the same way. Similarly, a nil array is stored as NULL, and an empty one as an empty array.
+// Only one-dimensional arrays are supported.
+type EncryptedValueArrayFactory[T any] []EncryptedValueFactory[T]
+
+// EncryptedValueArray is a built-in array type that uses the same crypter as [EncryptedValue].
+type EncryptedValueArray = EncryptedValueArrayFactory[dummy]
+
+// Value is a driver.Valuer implementation. It encrypts the elements and formats them as a PostgreSQL array literal.
+func (a EncryptedValueArrayFactory[T]) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, v := range a {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if v == nil {
+			buf.WriteString("NULL")
+			continue
+		}
+
+		var encData []byte
+		if len(v) > 0 {
+			if encData, err = crypter.Encrypt(v); err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+
+		// bytea elements are written in hex format, with the backslash escaped, e.g. "\\x0102"
+		buf.WriteByte('"')
+		if crypter.TextEncoding {
+			buf.WriteString(base64.StdEncoding.EncodeToString(encData))
+		} else {
+			buf.WriteString(`\\x`)
+			buf.WriteString(hex.EncodeToString(encData))
+		}
+		buf.WriteByte('"')
+	}
+
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
+
+// Scan is a sql.Scanner implementation. It parses a PostgreSQL array literal and decrypts the elements.
+func (a *EncryptedValueArrayFactory[T]) Scan(value interface{}) error {
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	data, ok := scanBytes(value)
+	if !ok {
+		return fmt.Errorf("unable to scan %T into EncryptedValueArray", value)
+	}
+
+	if data == nil {
+		*a = nil
+		return nil
+	}
+
+	elems, err := parseArray(data)
+	if err != nil {
+		return err
+	}
+
+	res := make(EncryptedValueArrayFactory[T], len(elems))
+	for i, elem := range elems {
+		if elem == nil {
+			continue
+		}
+
+		var encData []byte
+		if crypter.TextEncoding {
+			encData, err = crypter.scannedData(elem)
+		} else {
+			encData, err = decodeHexBytea(elem)
+		}
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+
+		if len(encData) == 0 {
+			res[i] = EncryptedValueFactory[T]{}
+			continue
+		}
+
+		if res[i], err = crypter.Decrypt(encData); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+
+	*a = res
+	return nil
+}
+
+// parseArray splits a one-dimensional PostgreSQL array literal into unquoted elements. NULL elements are returned as nil.
+func parseArray(s []byte) ([][]byte, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, ErrInvalidArray
+	}
+
+	s = s[1 : len(s)-1]
+	res := [][]byte{}
+	if len(bytes.TrimSpace(s)) == 0 {
+		return res, nil
+	}
+
+	for {
+		var elem []byte
+
+		if len(s) > 0 && s[0] == '"' {
+			elem = []byte{}
+
+			i := 1
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				elem = append(elem, s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated quoted element", ErrInvalidArray)
+			}
+
+			s = s[i+1:]
+		} else {
+			i := bytes.IndexByte(s, ',')
+			if i < 0 {
+				i = len(s)
+			}
+
+			raw := bytes.TrimSpace(s[:i])
+			switch {
+			case len(raw) == 0:
+				return nil, fmt.Errorf("%w: empty element", ErrInvalidArray)
+			case raw[0] == '{':
+				return nil, fmt.Errorf("%w: multidimensional arrays are not supported", ErrInvalidArray)
+			case !bytes.EqualFold(raw, []byte("NULL")):
+				elem = bytes.Clone(raw)
+			}
+
+			s = s[i:]
+		}
+
+		res = append(res, elem)
+		if len(s) == 0 {
+			return res, nil
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("%w: unexpected %q after element", ErrInvalidArray, s[0])
+		}
+		s = s[1:]
+	}
+}
+
+// decodeHexBytea decodes a bytea value in hex format, e.g. \x0102.
+func decodeHexBytea(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if !bytes.HasPrefix(data, []byte(`\x`)) {
+		return nil, errors.New("bytea value is not in hex format")
+	}
+
+	res := make([]byte, hex.DecodedLen(len(data)-2))
+	if _, err := hex.Decode(res, data[2:]); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
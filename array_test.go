@@ -0,0 +1,112 @@
+package silent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedValueArray(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	type EncryptedValueArray1 = EncryptedValueArrayFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type dummy2 struct{}
+	type EncryptedValue2 = EncryptedValueFactory[dummy2]
+	type EncryptedValueArray2 = EncryptedValueArrayFactory[dummy2]
+	BindCrypterTo[EncryptedValue2](&c1, WithTextEncoding())
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue2]() })
+
+	t.Run("round trip", func(t *testing.T) {
+		orig := EncryptedValueArray1{EncryptedValue1("Hello, World!"), nil, EncryptedValue1{}, EncryptedValue1(texts[2])}
+
+		enc, err := orig.Value()
+		RequireNoError(t, err)
+
+		s := enc.(string)
+		RequireTrue(t, strings.HasPrefix(s, `{"\\x01`))
+		RequireTrue(t, strings.Contains(s, `,NULL,"\\x",`))
+		RequireTrue(t, !strings.Contains(s, "Hello"))
+
+		var dec EncryptedValueArray1
+		RequireNoError(t, dec.Scan([]byte(s)))
+		RequireEqual(t, len(dec), len(orig))
+		RequireEqual(t, string(dec[0]), "Hello, World!")
+		RequireTrue(t, dec[1] == nil)
+		RequireTrue(t, dec[2] != nil && len(dec[2]) == 0)
+		RequireEqual(t, string(dec[3]), string(texts[2]))
+	})
+
+	t.Run("text", func(t *testing.T) {
+		orig := EncryptedValueArray2{EncryptedValue2("Hello, World!"), nil, EncryptedValue2{}}
+
+		enc, err := orig.Value()
+		RequireNoError(t, err)
+		RequireTrue(t, strings.HasPrefix(enc.(string), `{"AQEAAAA`))
+		RequireTrue(t, strings.HasSuffix(enc.(string), `",NULL,""}`))
+
+		var dec EncryptedValueArray2
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, len(dec), 3)
+		RequireEqual(t, string(dec[0]), "Hello, World!")
+		RequireTrue(t, dec[1] == nil)
+		RequireTrue(t, dec[2] != nil && len(dec[2]) == 0)
+
+		// postgres doesn't quote elements without special characters
+		unquoted := strings.Replace(enc.(string), `"`, "", 2)
+		dec = nil
+		RequireNoError(t, dec.Scan(unquoted))
+		RequireEqual(t, string(dec[0]), "Hello, World!")
+	})
+
+	t.Run("empty and null", func(t *testing.T) {
+		enc, err := EncryptedValueArray1{}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, enc, "{}")
+
+		dec := EncryptedValueArray1{EncryptedValue1("stale")}
+		RequireNoError(t, dec.Scan(enc))
+		RequireTrue(t, dec != nil && len(dec) == 0)
+
+		enc, err = EncryptedValueArray1(nil).Value()
+		RequireNoError(t, err)
+		RequireEqual(t, enc, nil)
+
+		RequireNoError(t, dec.Scan(enc))
+		RequireTrue(t, dec == nil)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []string{"", "{", "1,2", `{"\\x01`, "{{1},{2}}", "{,}", `{"a"x}`} {
+			var dec EncryptedValueArray1
+			err := dec.Scan(s)
+			RequireTrue(t, errors.Is(err, ErrInvalidArray))
+		}
+
+		var dec EncryptedValueArray1
+		RequireError(t, dec.Scan(`{"abc"}`))
+		RequireError(t, dec.Scan(`{"\\xzz"}`))
+		RequireError(t, dec.Scan(42))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		enc, err := EncryptedValueArray1{EncryptedValue1("Hello, World!")}.Value()
+		RequireNoError(t, err)
+
+		// change the last hex digit
+		s := []byte(enc.(string))
+		if s[len(s)-3] == '0' {
+			s[len(s)-3] = '1'
+		} else {
+			s[len(s)-3] = '0'
+		}
+
+		var dec EncryptedValueArray1
+		RequireError(t, dec.Scan(s))
+	})
+}
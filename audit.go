@@ -0,0 +1,27 @@
+package silent
+
+import "reflect"
+
+// DecryptInfo describes a single decryption, as reported to the callback set by [WithOnDecrypt].
+// It never contains the plaintext.
+type DecryptInfo struct {
+	Type reflect.Type // the type parameter of the value, e.g. the T in EncryptedValueFactory[T]
+	Size int          // size of the encrypted data
+	Err  error        // nil if decryption succeeded
+}
+
+// WithOnDecrypt sets a callback that is called each time a value of the bound type is decrypted,
+// successfully or not, for example to write an audit log entry. It's called once per non-empty value, in Scan,
+// UnmarshalJSON and other decoding methods. Empty values are not decrypted, so they're not reported.
+//
+// The callback is called synchronously, so it should be fast. It only receives metadata, never the plaintext.
+func WithOnDecrypt(fn func(info DecryptInfo)) BindOption {
+	return func(m *crypterMapping) {
+		m.OnDecrypt = fn
+	}
+}
+
+// auditDecrypt reports a decryption to the OnDecrypt callback. It's meant to be deferred, since err is only known at the end.
+func (m *crypterMapping) auditDecrypt(size int, err *error) {
+	m.OnDecrypt(DecryptInfo{Type: m.Type, Size: size, Err: *err})
+}
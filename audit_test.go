@@ -0,0 +1,74 @@
+package silent
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOnDecrypt(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	var infos []DecryptInfo
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1, WithOnDecrypt(func(info DecryptInfo) {
+		infos = append(infos, info)
+	}))
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	enc, err := EncryptedValue1("Hello, World!").Value()
+	RequireNoError(t, err)
+	encData := enc.([]byte)
+
+	t.Run("scan", func(t *testing.T) {
+		infos = nil
+
+		var v EncryptedValue1
+		RequireNoError(t, v.Scan(encData))
+
+		RequireEqual(t, len(infos), 1)
+		RequireEqual(t, infos[0].Type, reflect.TypeOf(dummy1{}))
+		RequireEqual(t, infos[0].Size, len(encData))
+		RequireNoError(t, infos[0].Err)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		infos = nil
+
+		js, err := json.Marshal(EncryptedValue1("Hello, World!"))
+		RequireNoError(t, err)
+
+		var v EncryptedValue1
+		RequireNoError(t, json.Unmarshal(js, &v))
+
+		RequireEqual(t, len(infos), 1)
+		RequireNoError(t, infos[0].Err)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		infos = nil
+
+		tampered := append([]byte{}, encData...)
+		tampered[len(tampered)-1] ^= 1
+
+		var v EncryptedValue1
+		RequireError(t, v.Scan(tampered))
+
+		RequireEqual(t, len(infos), 1)
+		RequireEqual(t, infos[0].Size, len(tampered))
+		RequireError(t, infos[0].Err)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		infos = nil
+
+		var v EncryptedValue1
+		RequireNoError(t, v.Scan([]byte{}))
+		RequireNoError(t, v.Scan(nil))
+
+		RequireEqual(t, len(infos), 0)
+	})
+}
@@ -0,0 +1,69 @@
+package silent
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// BlindIndex computes deterministic tokens that allow searching by values stored in randomly encrypted columns.
+// The token is a keyed HMAC-SHA256 of the normalized plaintext, truncated to the configured size.
+// Typically, the token is stored in a separate indexed column next to the encrypted value:
+//
+//	u.Email = silent.EncryptedValue(email)
+//	u.EmailIdx = bidx.Compute([]byte(email))
+//
+// and then the table is queried by the token: WHERE email_idx = ?.
+//
+// The token is one-way: the value can't be recovered from it, even with the key.
+// Still, equal values produce equal tokens, so anyone who can see the tokens can tell which rows have equal values.
+// Shorter tokens leak less, since unrelated values start to collide, but lookups return more false positives,
+// which must be filtered out after decryption.
+type BlindIndex struct {
+	key  []byte
+	size int
+
+	// Lowercase makes the index case-insensitive, by converting the plaintext to lower case before hashing.
+	Lowercase bool
+
+	// TrimSpace removes leading and trailing white space from the plaintext before hashing.
+	TrimSpace bool
+}
+
+// NewBlindIndex creates a new BlindIndex with the given key and token size in bytes.
+// The key must be at least 32 bytes long, and should be different from the keys used for encryption.
+// The size must be between 1 and 32 bytes.
+func NewBlindIndex(key []byte, size int) *BlindIndex {
+	if len(key) < 32 {
+		panic("misconfiguration: key must be at least 32 bytes")
+	}
+
+	if size < 1 || size > sha256.Size {
+		panic("misconfiguration: blind index size must be between 1 and 32 bytes")
+	}
+
+	return &BlindIndex{
+		key:  key,
+		size: size,
+	}
+}
+
+// Compute returns the token for the plaintext.
+// An empty plaintext, or a plaintext that is empty after normalization, produces an empty token.
+func (b *BlindIndex) Compute(plaintext []byte) []byte {
+	if b.TrimSpace {
+		plaintext = bytes.TrimSpace(plaintext)
+	}
+
+	if b.Lowercase {
+		plaintext = bytes.ToLower(plaintext)
+	}
+
+	if len(plaintext) == 0 {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write(plaintext)
+	return mac.Sum(nil)[:b.size]
+}
@@ -0,0 +1,38 @@
+package silent
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBlindIndex(t *testing.T) {
+	b1 := NewBlindIndex(DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), 16)
+	b2 := NewBlindIndex(DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="), 16)
+
+	t.Run("compute", func(t *testing.T) {
+		token := b1.Compute([]byte("john@example.com"))
+		RequireEqual(t, len(token), 16)
+		RequireEqual(t, b1.Compute([]byte("john@example.com")), token)
+
+		RequireTrue(t, !bytes.Equal(b1.Compute([]byte("jane@example.com")), token))
+		RequireTrue(t, !bytes.Equal(b2.Compute([]byte("john@example.com")), token))
+
+		RequireEqual(t, len(b1.Compute(nil)), 0)
+	})
+
+	t.Run("size", func(t *testing.T) {
+		b := NewBlindIndex(DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), 4)
+		token := b.Compute([]byte("john@example.com"))
+		RequireEqual(t, token, b1.Compute([]byte("john@example.com"))[:4])
+	})
+
+	t.Run("normalization", func(t *testing.T) {
+		b := NewBlindIndex(DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), 16)
+		RequireTrue(t, !bytes.Equal(b.Compute([]byte(" John@Example.com ")), b1.Compute([]byte("john@example.com"))))
+
+		b.Lowercase = true
+		b.TrimSpace = true
+		RequireEqual(t, b.Compute([]byte(" John@Example.com ")), b1.Compute([]byte("john@example.com")))
+		RequireEqual(t, len(b.Compute([]byte("  "))), 0)
+	})
+}
@@ -0,0 +1,86 @@
+package silent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// BSON type codes, as defined in https://bsonspec.org/spec.html
+const (
+	bsonTypeBinary    byte = 0x05
+	bsonTypeUndefined byte = 0x06
+	bsonTypeNull      byte = 0x0A
+
+	bsonSubtypeGeneric byte = 0x00
+)
+
+// MarshalBSONValue implements the bson.ValueMarshaler interface of go.mongodb.org/mongo-driver/v2.
+// It encrypts the value and stores it as BSON binary data with the generic subtype.
+// Empty values are stored as empty binary data.
+func (v EncryptedValueFactory[T]) MarshalBSONValue() (byte, []byte, error) {
+	if len(v) == 0 {
+		return bsonTypeBinary, appendBSONBinary(nil, nil), nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	encData, err := crypter.Encrypt(v)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return bsonTypeBinary, appendBSONBinary(make([]byte, 0, len(encData)+5), encData), nil
+}
+
+// UnmarshalBSONValue implements the bson.ValueUnmarshaler interface of go.mongodb.org/mongo-driver/v2.
+// It decrypts the value from BSON binary data. Null is decoded as an empty value.
+func (v *EncryptedValueFactory[T]) UnmarshalBSONValue(typ byte, data []byte) error {
+	switch typ {
+	case bsonTypeNull, bsonTypeUndefined:
+		*v = nil
+		return nil
+	case bsonTypeBinary:
+	default:
+		return fmt.Errorf("unable to unmarshal BSON type 0x%02x into EncryptedValue", typ)
+	}
+
+	if len(data) < 5 {
+		return io.ErrUnexpectedEOF
+	}
+
+	size := binary.LittleEndian.Uint32(data[:4])
+	if subtype := data[4]; subtype != bsonSubtypeGeneric {
+		return fmt.Errorf("unable to unmarshal BSON binary subtype 0x%02x into EncryptedValue", subtype)
+	}
+	if uint64(size) != uint64(len(data)-5) {
+		return io.ErrUnexpectedEOF
+	}
+
+	if size == 0 {
+		*v = nil
+		return nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	res, err := crypter.Decrypt(data[5:])
+	if err != nil {
+		return err
+	}
+
+	*v = res
+	return nil
+}
+
+func appendBSONBinary(dst, data []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(data)))
+	dst = append(dst, bsonSubtypeGeneric)
+	return append(dst, data...)
+}
@@ -0,0 +1,73 @@
+package silent
+
+import (
+	"bytes"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestEncryptedValueBSON(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type user struct {
+		Username string          `bson:"username"`
+		Token    EncryptedValue1 `bson:"token"`
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, text := range texts {
+			orig := user{Username: "john", Token: EncryptedValue1(text)}
+
+			enc, err := bson.Marshal(orig)
+			RequireNoError(t, err)
+
+			if len(text) > 0 && bytes.Contains(enc, text) {
+				t.Fatalf("encrypted document contains plaintext")
+			}
+
+			var dec user
+			RequireNoError(t, bson.Unmarshal(enc, &dec))
+			RequireEqual(t, dec.Username, orig.Username)
+			RequireEqual(t, string(dec.Token), string(orig.Token))
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		enc, err := bson.Marshal(user{Username: "john", Token: EncryptedValue1("Hello, World!")})
+		RequireNoError(t, err)
+
+		var raw struct {
+			Token bson.Binary `bson:"token"`
+		}
+		RequireNoError(t, bson.Unmarshal(enc, &raw))
+		RequireEqual(t, raw.Token.Subtype, byte(0x00))
+
+		dec, err := c1.Decrypt(raw.Token.Data)
+		RequireNoError(t, err)
+		RequireEqual(t, string(dec), "Hello, World!")
+	})
+
+	t.Run("null", func(t *testing.T) {
+		enc, err := bson.Marshal(bson.D{{Key: "username", Value: "john"}, {Key: "token", Value: nil}})
+		RequireNoError(t, err)
+
+		dec := user{Token: EncryptedValue1("stale")}
+		RequireNoError(t, bson.Unmarshal(enc, &dec))
+		RequireEqual(t, len(dec.Token), 0)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		enc, err := bson.Marshal(bson.D{{Key: "token", Value: 42}})
+		RequireNoError(t, err)
+
+		var dec user
+		RequireError(t, bson.Unmarshal(enc, &dec))
+	})
+}
@@ -0,0 +1,259 @@
+package silent
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// CachingCrypter is a [Crypter] implementation that memoizes decryption results of the inner crypter.
+// It helps in read-heavy workloads, where the same encrypted value, such as a shared secret, is decrypted over and over again,
+// especially with expensive inner crypters that call a KMS or Vault.
+// Results are keyed by the SHA-256 hash of the encrypted data, and evicted when they expire,
+// or when the cache is full, starting from the least recently used ones. Encryption is never cached,
+// since it produces different data every time. Errors are not cached either.
+//
+// The tradeoff is that decrypted data stays in memory for up to the TTL, where it may end up in core dumps,
+// swap, or be exposed by memory-disclosure bugs. Use short TTLs, call [CachingCrypter.Purge] when the data
+// is no longer needed, and disable caching with a zero max size where that's not acceptable.
+//
+// For the same reason, revocation is delayed: after a key is removed from the inner crypter,
+// e.g. with [MultiKeyCrypter.RemoveKey], values encrypted with it are still served from the cache until they expire.
+// Call Purge right after removing a key to make the revocation take effect immediately.
+//
+// The optional interfaces of the inner crypter, such as [AADCrypter], [ContextCrypter] and [BypassDetector],
+// are forwarded to it. Decryption with associated data is cached separately for each aad.
+//
+// It is safe for concurrent use, as long as the inner crypter is.
+type CachingCrypter struct {
+	inner   Crypter
+	maxSize int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[[sha256.Size]byte]*list.Element
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+var (
+	_ AADCrypter     = (*CachingCrypter)(nil)
+	_ ContextCrypter = (*CachingCrypter)(nil)
+	_ BypassDetector = (*CachingCrypter)(nil)
+)
+
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	data    []byte
+	expires time.Time
+}
+
+// NewCachingCrypter creates a new CachingCrypter that wraps the inner crypter and caches up to maxSize decrypted values,
+// each for at most ttl. A zero ttl means that values don't expire, and a zero maxSize disables caching altogether.
+func NewCachingCrypter(inner Crypter, maxSize int, ttl time.Duration) *CachingCrypter {
+	if inner == nil {
+		panic("misconfiguration: inner crypter is nil")
+	}
+
+	if maxSize < 0 {
+		panic("misconfiguration: max size must not be negative")
+	}
+
+	if ttl < 0 {
+		panic("misconfiguration: ttl must not be negative")
+	}
+
+	return &CachingCrypter{
+		inner:   inner,
+		maxSize: maxSize,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Encrypt encrypts the data using the inner crypter.
+func (s *CachingCrypter) Encrypt(data []byte) ([]byte, error) {
+	return s.inner.Encrypt(data)
+}
+
+// Decrypt returns the cached result if there is one, or decrypts the data using the inner crypter and caches the result.
+// The returned slice is always a copy, so it's safe to modify.
+func (s *CachingCrypter) Decrypt(data []byte) ([]byte, error) {
+	return s.decrypt(data, nil, s.inner.Decrypt)
+}
+
+// EncryptWithAAD encrypts the data using the inner crypter, which must implement [AADCrypter].
+func (s *CachingCrypter) EncryptWithAAD(data, aad []byte) ([]byte, error) {
+	inner, ok := s.inner.(AADCrypter)
+	if !ok {
+		return nil, ErrAADNotSupported
+	}
+
+	return inner.EncryptWithAAD(data, aad)
+}
+
+// DecryptWithAAD is like [CachingCrypter.Decrypt], but decrypts the data using [AADCrypter.DecryptWithAAD] of the inner crypter.
+func (s *CachingCrypter) DecryptWithAAD(data, aad []byte) ([]byte, error) {
+	inner, ok := s.inner.(AADCrypter)
+	if !ok {
+		return nil, ErrAADNotSupported
+	}
+
+	return s.decrypt(data, aad, func(data []byte) ([]byte, error) {
+		return inner.DecryptWithAAD(data, aad)
+	})
+}
+
+// EncryptContext encrypts the data using the inner crypter, passing ctx to it if it implements [ContextCrypter].
+func (s *CachingCrypter) EncryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	inner, ok := s.inner.(ContextCrypter)
+	if !ok {
+		return s.inner.Encrypt(data)
+	}
+
+	return inner.EncryptContext(ctx, data)
+}
+
+// DecryptContext is like [CachingCrypter.Decrypt], but passes ctx to the inner crypter if it implements [ContextCrypter].
+// Cached results are returned regardless of ctx.
+func (s *CachingCrypter) DecryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	inner, ok := s.inner.(ContextCrypter)
+	if !ok {
+		return s.Decrypt(data)
+	}
+
+	return s.decrypt(data, nil, func(data []byte) ([]byte, error) {
+		return inner.DecryptContext(ctx, data)
+	})
+}
+
+// decrypt returns the cached result for data and aad, or calls decrypt and caches its result.
+func (s *CachingCrypter) decrypt(data, aad []byte, decrypt func(data []byte) ([]byte, error)) ([]byte, error) {
+	if len(data) == 0 || s.maxSize == 0 {
+		return decrypt(data)
+	}
+
+	key := cacheKey(data, aad)
+	if res, ok := s.get(key); ok {
+		return res, nil
+	}
+
+	res, err := decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.put(key, res)
+	return res, nil
+}
+
+// cacheKey returns the SHA-256 hash of the data, prefixed with the length-prefixed aad if there is one.
+// Empty aad is the same as no aad at all, as with [AADCrypter].
+func cacheKey(data, aad []byte) [sha256.Size]byte {
+	if len(aad) == 0 {
+		return sha256.Sum256(data)
+	}
+
+	h := sha256.New()
+	h.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(aad))))
+	h.Write(aad)
+	h.Write(data)
+
+	var res [sha256.Size]byte
+	h.Sum(res[:0])
+	return res
+}
+
+func (s *CachingCrypter) get(key [sha256.Size]byte) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if s.ttl > 0 && !s.now().Before(entry.expires) {
+		s.remove(elem)
+		return nil, false
+	}
+
+	s.lru.MoveToFront(elem)
+	return bytes.Clone(entry.data), true
+}
+
+func (s *CachingCrypter) put(key [sha256.Size]byte, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the value could have been cached by a concurrent call
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+
+	entry := &cacheEntry{key: key, data: bytes.Clone(data)}
+	if s.ttl > 0 {
+		entry.expires = s.now().Add(s.ttl)
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+
+	for s.lru.Len() > s.maxSize {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove evicts the entry and zeroes the cached data. It must be called with s.mu held.
+func (s *CachingCrypter) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*cacheEntry)
+	delete(s.entries, entry.key)
+	clear(entry.data)
+}
+
+// Purge evicts all cached values and zeroes their data.
+func (s *CachingCrypter) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.lru.Len() > 0 {
+		s.remove(s.lru.Back())
+	}
+}
+
+// Len returns the number of cached values, including the expired ones that weren't evicted yet.
+func (s *CachingCrypter) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lru.Len()
+}
+
+func (s *CachingCrypter) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// EncryptedSize returns the size of the data encrypted by the inner crypter.
+// It requires the inner crypter to report the encrypted size as well, otherwise [ErrSizeNotSupported] is returned.
+func (s *CachingCrypter) EncryptedSize(dataSize int) (int, error) {
+	inner, ok := s.inner.(interface{ EncryptedSize(int) (int, error) })
+	if !ok {
+		return 0, ErrSizeNotSupported
+	}
+
+	return inner.EncryptedSize(dataSize)
+}
+
+// IsBypassed reports whether the inner crypter detects the data as produced in bypass mode. See [BypassDetector].
+func (s *CachingCrypter) IsBypassed(data []byte) bool {
+	return isBypassed(s.inner, data)
+}
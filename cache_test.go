@@ -0,0 +1,232 @@
+package silent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCrypter counts the calls to Decrypt of the inner crypter.
+type countingCrypter struct {
+	Crypter
+	decrypts atomic.Int64
+}
+
+func (c *countingCrypter) Decrypt(data []byte) ([]byte, error) {
+	c.decrypts.Add(1)
+	return c.Crypter.Decrypt(data)
+}
+
+func TestCachingCrypter(t *testing.T) {
+	mk := MultiKeyCrypter{}
+	mk.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	other := MultiKeyCrypter{}
+	other.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		c := NewCachingCrypter(&mk, 10, time.Minute)
+
+		runCrypterSubtests(t, "c should decrypt self", c, c)
+		runCrypterSubtests(t, "c should decrypt mk", c, &mk)
+		runCrypterSubtests(t, "mk should decrypt c", &mk, c)
+		runCrypterSubtests(t, "c should not decrypt other", c, &other)
+	})
+
+	t.Run("hits", func(t *testing.T) {
+		inner := &countingCrypter{Crypter: &mk}
+		c := NewCachingCrypter(inner, 10, time.Minute)
+
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			text, err := c.Decrypt(enc)
+			RequireNoError(t, err)
+			RequireEqual(t, string(text), "Hello, World!")
+
+			// the result is a copy, so modifying it doesn't affect the cache
+			text[0] = 'J'
+		}
+		RequireEqual(t, inner.decrypts.Load(), int64(1))
+
+		// errors are not cached
+		enc2, err := other.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		for i := 0; i < 2; i++ {
+			_, err = c.Decrypt(enc2)
+			RequireErrorIs(t, err, ErrUnknownKey)
+		}
+		RequireEqual(t, inner.decrypts.Load(), int64(3))
+		RequireEqual(t, c.Len(), 1)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		inner := &countingCrypter{Crypter: &mk}
+		c := NewCachingCrypter(inner, 10, time.Minute)
+
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		c.Now = func() time.Time { return now }
+
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = c.Decrypt(enc)
+		RequireNoError(t, err)
+
+		now = now.Add(59 * time.Second)
+		_, err = c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, inner.decrypts.Load(), int64(1))
+
+		now = now.Add(time.Second)
+		text, err := c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, string(text), "Hello, World!")
+		RequireEqual(t, inner.decrypts.Load(), int64(2))
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		inner := &countingCrypter{Crypter: &mk}
+		c := NewCachingCrypter(inner, 2, 0)
+
+		var encs [3][]byte
+		for i := range encs {
+			var err error
+			encs[i], err = c.Encrypt([]byte("Hello, World!"))
+			RequireNoError(t, err)
+		}
+
+		decrypt := func(i int) {
+			t.Helper()
+			text, err := c.Decrypt(encs[i])
+			RequireNoError(t, err)
+			RequireEqual(t, string(text), "Hello, World!")
+		}
+
+		decrypt(0)
+		decrypt(1)
+		decrypt(0) // 0 becomes the most recently used
+		decrypt(2) // evicts 1
+		RequireEqual(t, inner.decrypts.Load(), int64(3))
+		RequireEqual(t, c.Len(), 2)
+
+		decrypt(0)
+		RequireEqual(t, inner.decrypts.Load(), int64(3))
+		decrypt(1)
+		RequireEqual(t, inner.decrypts.Load(), int64(4))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		inner := &countingCrypter{Crypter: &mk}
+		c := NewCachingCrypter(inner, 0, time.Minute)
+
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := c.Decrypt(enc)
+			RequireNoError(t, err)
+		}
+		RequireEqual(t, inner.decrypts.Load(), int64(3))
+		RequireEqual(t, c.Len(), 0)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		inner := &countingCrypter{Crypter: &mk}
+		c := NewCachingCrypter(inner, 10, time.Minute)
+
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, c.Len(), 1)
+
+		c.Purge()
+		RequireEqual(t, c.Len(), 0)
+
+		_, err = c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, inner.decrypts.Load(), int64(2))
+	})
+
+	t.Run("revocation", func(t *testing.T) {
+		rotated := MultiKeyCrypter{}
+		rotated.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c := NewCachingCrypter(&rotated, 10, time.Minute)
+
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		_, err = c.Decrypt(enc)
+		RequireNoError(t, err)
+
+		rotated.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+		RequireNoError(t, rotated.RemoveKey(0x1))
+
+		// the cached value is still served until it's purged
+		text, err := c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, string(text), "Hello, World!")
+
+		c.Purge()
+		_, err = c.Decrypt(enc)
+		RequireErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("aad", func(t *testing.T) {
+		c := NewCachingCrypter(&mk, 10, time.Minute)
+
+		enc, err := c.EncryptWithAAD([]byte("Hello, World!"), []byte("row 1"))
+		RequireNoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			text, err := c.DecryptWithAAD(enc, []byte("row 1"))
+			RequireNoError(t, err)
+			RequireEqual(t, string(text), "Hello, World!")
+		}
+		RequireEqual(t, c.Len(), 1)
+
+		// results are cached per aad, so a cached value is never returned for a different one
+		_, err = c.DecryptWithAAD(enc, []byte("row 2"))
+		RequireErrorIs(t, err, ErrAuthentication)
+		_, err = c.Decrypt(enc)
+		RequireErrorIs(t, err, ErrAuthentication)
+
+		// inner crypters without AAD support
+		c = NewCachingCrypter(&countingCrypter{Crypter: &mk}, 10, time.Minute)
+		_, err = c.EncryptWithAAD([]byte("Hello, World!"), []byte("row 1"))
+		RequireErrorIs(t, err, ErrAADNotSupported)
+		_, err = c.DecryptWithAAD(enc, []byte("row 1"))
+		RequireErrorIs(t, err, ErrAADNotSupported)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		inner := &recordingCrypter{}
+		inner.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c := NewCachingCrypter(inner, 10, time.Minute)
+
+		ctx := context.WithValue(context.Background(), ctxKey{}, "request 1")
+		enc, err := c.EncryptContext(ctx, []byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			text, err := c.DecryptContext(ctx, enc)
+			RequireNoError(t, err)
+			RequireEqual(t, string(text), "Hello, World!")
+		}
+
+		// the second decryption is served from the cache
+		RequireEqual(t, inner.seen, []any{"request 1", "request 1"})
+	})
+
+	t.Run("bypass", func(t *testing.T) {
+		c := NewCachingCrypter(&mk, 10, time.Minute)
+		RequireTrue(t, c.IsBypassed([]byte("#Hello, World!")))
+		RequireTrue(t, !c.IsBypassed([]byte("Hello, World!")))
+
+		c = NewCachingCrypter(NoOpCrypter{}, 10, time.Minute)
+		RequireTrue(t, !c.IsBypassed([]byte("#Hello, World!")))
+	})
+}
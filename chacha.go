@@ -0,0 +1,67 @@
+package silent
+
+import (
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ChaCha20Crypter is a [Crypter] implementation based on ChaCha20-Poly1305.
+// It is a good alternative to [GCMCrypter] on machines without AES hardware acceleration.
+// Both the standard variant with 12-byte nonces and XChaCha20-Poly1305 with 24-byte nonces are supported.
+// The latter has a bit more overhead, but random nonces are safe to use with it for any practical number of messages.
+//
+// Like MultiKeyCrypter, it supports multiple keys: the last added key is used for encryption,
+// and the key ID embedded in the encrypted data is used to select the key for decryption.
+type ChaCha20Crypter struct {
+	keyring  aeadKeyring
+	extended bool
+
+	// Rand is the source of random nonces, the same as [GCMCrypter.Rand].
+	Rand io.Reader
+}
+
+// NewChaCha20Crypter creates a new ChaCha20Crypter that uses 12-byte nonces.
+func NewChaCha20Crypter() *ChaCha20Crypter {
+	return &ChaCha20Crypter{}
+}
+
+// NewXChaCha20Crypter creates a new ChaCha20Crypter that uses the XChaCha20-Poly1305 variant with 24-byte nonces.
+func NewXChaCha20Crypter() *ChaCha20Crypter {
+	return &ChaCha20Crypter{extended: true}
+}
+
+// AddKey adds a new key to the crypter.
+// The keyID must be unique and the key must be exactly 32 bytes long.
+func (c *ChaCha20Crypter) AddKey(keyID uint32, key []byte) {
+	if c.extended {
+		c.keyring.addKey(keyID, key, chacha20poly1305.NewX)
+	} else {
+		c.keyring.addKey(keyID, key, chacha20poly1305.New)
+	}
+}
+
+// Encrypt encrypts the data using the last added key.
+func (c *ChaCha20Crypter) Encrypt(data []byte) ([]byte, error) {
+	return c.keyring.encrypt(data, c.Rand, nil)
+}
+
+// Decrypt decrypts the data.
+// The key is automatically selected based on the key ID embedded in the data.
+func (c *ChaCha20Crypter) Decrypt(data []byte) ([]byte, error) {
+	return c.keyring.decrypt(data, nil)
+}
+
+// EncryptedSize returns the size of the encrypted data.
+func (c *ChaCha20Crypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	nonceSize := chacha20poly1305.NonceSize
+	if c.extended {
+		nonceSize = chacha20poly1305.NonceSizeX
+	}
+
+	return aeadHeaderSize + nonceSize + dataSize + chacha20poly1305.Overhead, nil
+}
@@ -0,0 +1,104 @@
+package silent
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestChaCha20Crypter(t *testing.T) {
+	for _, variant := range []struct {
+		name string
+		new  func() *ChaCha20Crypter
+	}{
+		{"ChaCha20", NewChaCha20Crypter},
+		{"XChaCha20", NewXChaCha20Crypter},
+	} {
+		t.Run(variant.name, func(t *testing.T) {
+			c1 := variant.new()
+			c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+			// same as c1, but with additional key
+			c2 := variant.new()
+			c2.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+			c2.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+			// same key id as in c1, but the key itself is different
+			c1broken := variant.new()
+			c1broken.AddKey(0x1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+
+			runCrypterSubtests(t, "c1 should decrypt self", c1, c1)
+			runCrypterSubtests(t, "c1 should not decrypt c2", c1, c2)
+			runCrypterSubtests(t, "c1 should not decrypt c1broken", c1, c1broken)
+
+			runCrypterSubtests(t, "c2 should decrypt self", c2, c2)
+			runCrypterSubtests(t, "c2 should decrypt c1", c2, c1)
+			runCrypterSubtests(t, "c2 should not decrypt c1broken", c2, c1broken)
+
+			t.Run("encrypt", func(t *testing.T) {
+				text := []byte("Hello, World!")
+				encryptedText, err := c1.Encrypt(text)
+				RequireNoError(t, err)
+
+				if bytes.Contains(encryptedText, text) {
+					t.Fatalf("encrypted text contains plaintext")
+				}
+			})
+		})
+	}
+
+	t.Run("variants are not interchangeable", func(t *testing.T) {
+		c := NewChaCha20Crypter()
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		cx := NewXChaCha20Crypter()
+		cx.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		runCrypterSubtests(t, "c should not decrypt cx", c, cx)
+		runCrypterSubtests(t, "cx should not decrypt c", cx, c)
+	})
+}
+
+func BenchmarkChaCha20Crypter(b *testing.B) {
+	key := make([]byte, 32)
+
+	multiKey := &MultiKeyCrypter{}
+	multiKey.AddKey(0x1, key)
+
+	chacha := NewChaCha20Crypter()
+	chacha.AddKey(0x1, key)
+
+	xchacha := NewXChaCha20Crypter()
+	xchacha.AddKey(0x1, key)
+
+	crypters := []struct {
+		name    string
+		crypter Crypter
+	}{
+		{"MultiKey", multiKey},
+		{"ChaCha20", chacha},
+		{"XChaCha20", xchacha},
+	}
+
+	for _, size := range []int{16, 1024} {
+		data := make([]byte, size)
+
+		for _, c := range crypters {
+			b.Run(fmt.Sprintf("%s/%dB", c.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(size))
+
+				for i := 0; i < b.N; i++ {
+					encData, err := c.crypter.Encrypt(data)
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					if _, err := c.crypter.Decrypt(encData); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
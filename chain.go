@@ -0,0 +1,75 @@
+package silent
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ChainCrypter is a [Crypter] implementation that helps to migrate between encryption schemes.
+// It encrypts data with the primary crypter, and tries to decrypt data with each crypter in order,
+// until one of them succeeds. This allows to read data in both the legacy and the new formats,
+// while new data is always written in the new format.
+//
+// Crypters that accept arbitrary data, such as [NoOpCrypter] or [MultiKeyCrypter] for data produced in bypass mode,
+// should go last, since decryption never falls through them.
+type ChainCrypter struct {
+	crypters []Crypter
+}
+
+// NewChainCrypter creates a new ChainCrypter that encrypts with primary, and decrypts with primary or any of the fallbacks.
+func NewChainCrypter(primary Crypter, fallbacks ...Crypter) *ChainCrypter {
+	crypters := make([]Crypter, 0, len(fallbacks)+1)
+	crypters = append(crypters, primary)
+	crypters = append(crypters, fallbacks...)
+
+	for _, c := range crypters {
+		if c == nil {
+			panic("misconfiguration: crypter is nil")
+		}
+	}
+
+	return &ChainCrypter{crypters: crypters}
+}
+
+// Encrypt encrypts the data using the primary crypter.
+func (s *ChainCrypter) Encrypt(data []byte) ([]byte, error) {
+	return s.crypters[0].Encrypt(data)
+}
+
+// Decrypt decrypts the data using the first crypter that succeeds.
+// If all of them fail, the returned error joins the errors of all crypters, in order.
+func (s *ChainCrypter) Decrypt(data []byte) ([]byte, error) {
+	var errs []error
+	for i, c := range s.crypters {
+		res, err := c.Decrypt(data)
+		if err == nil {
+			return res, nil
+		}
+
+		errs = append(errs, fmt.Errorf("crypter %d: %w", i, err))
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// EncryptedSize returns the size of the data encrypted by the primary crypter.
+// It requires the primary crypter to report the encrypted size as well, otherwise [ErrSizeNotSupported] is returned.
+func (s *ChainCrypter) EncryptedSize(dataSize int) (int, error) {
+	primary, ok := s.crypters[0].(interface{ EncryptedSize(int) (int, error) })
+	if !ok {
+		return 0, ErrSizeNotSupported
+	}
+
+	return primary.EncryptedSize(dataSize)
+}
+
+// IsBypassed reports whether any of the crypters detects the data as produced in bypass mode. See [BypassDetector].
+// Any crypter may end up decrypting the data, so it errs on the side of reporting bypass.
+func (s *ChainCrypter) IsBypassed(data []byte) bool {
+	for _, c := range s.crypters {
+		if isBypassed(c, data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package silent
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChainCrypter(t *testing.T) {
+	legacy := MultiKeyCrypter{}
+	legacy.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	current := MultiKeyCrypter{}
+	current.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+	other := MultiKeyCrypter{}
+	other.AddKey(0x3, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+
+	c := NewChainCrypter(&current, &legacy)
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "c should decrypt self", c, c)
+		runCrypterSubtests(t, "c should decrypt legacy", c, &legacy)
+		runCrypterSubtests(t, "c should decrypt current", c, &current)
+		runCrypterSubtests(t, "current should decrypt c", &current, c)
+		runCrypterSubtests(t, "legacy should not decrypt c", &legacy, c)
+		runCrypterSubtests(t, "c should not decrypt other", c, &other)
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		enc, err := other.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = c.Decrypt(enc)
+		RequireErrorIs(t, err, ErrUnknownKey)
+
+		var joined interface{ Unwrap() []error }
+		RequireTrue(t, errors.As(err, &joined))
+		RequireEqual(t, len(joined.Unwrap()), 2)
+	})
+}
@@ -0,0 +1,16 @@
+// Command silent encrypts, decrypts and re-encrypts data from stdin. Run it without arguments for usage.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/destel/silent/silentcli"
+)
+
+func main() {
+	if err := silentcli.Run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "silent:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,149 @@
+package silent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+var (
+	ErrUnknownCompression = errors.New("unknown compression method")
+	ErrSizeNotSupported   = errors.New("inner crypter does not report encrypted size")
+)
+
+const (
+	compressionNone byte = 0
+	compressionGzip byte = 1
+)
+
+// DefaultMaxDecompressedSize is the limit of [CompressingCrypter.MaxDecompressedSize] used when it's zero.
+const DefaultMaxDecompressedSize = 64 << 20
+
+// CompressingCrypter is a [Crypter] implementation that gzip-compresses the data before passing it to the inner crypter.
+// This is useful for large, repetitive values such as JSON documents, since encrypted data can't be compressed afterwards.
+// The data is stored uncompressed if compression doesn't make it smaller, which is typical for tiny values.
+// The choice is recorded in a header byte, which is encrypted together with the data.
+//
+// Beware that compression makes the length of the encrypted data depend on the contents of the plaintext.
+// If an attacker can influence a part of the plaintext and observe the length of the result,
+// they may be able to recover the rest of it, as in CRIME/BREACH attacks.
+// Don't use this crypter for values that mix secrets with attacker-controlled data.
+type CompressingCrypter struct {
+	inner Crypter
+
+	// MaxDecompressedSize limits the size of decompressed data, which protects from decompression bombs:
+	// tiny values that expand to gigabytes. Decrypt fails with [ErrTooLarge] once the limit is exceeded.
+	// Zero means [DefaultMaxDecompressedSize], and a negative value disables the limit.
+	MaxDecompressedSize int
+}
+
+// NewCompressingCrypter creates a new CompressingCrypter that wraps the inner crypter.
+func NewCompressingCrypter(inner Crypter) *CompressingCrypter {
+	if inner == nil {
+		panic("misconfiguration: inner crypter is nil")
+	}
+
+	return &CompressingCrypter{inner: inner}
+}
+
+// Encrypt compresses the data if that makes it smaller, and encrypts the result using the inner crypter.
+func (s *CompressingCrypter) Encrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + 1)
+	buf.WriteByte(compressionGzip)
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	payload := buf.Bytes()
+	if len(payload) > len(data) {
+		payload = make([]byte, 0, len(data)+1)
+		payload = append(payload, compressionNone)
+		payload = append(payload, data...)
+	}
+
+	return s.inner.Encrypt(payload)
+}
+
+// Decrypt decrypts the data using the inner crypter, and decompresses the result if needed.
+func (s *CompressingCrypter) Decrypt(data []byte) ([]byte, error) {
+	payload, err := s.inner.Decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	switch payload[0] {
+	case compressionNone:
+		return payload[1:], nil
+
+	case compressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		var r io.Reader = zr
+		if limit := s.maxDecompressedSize(); limit >= 0 {
+			r = &sizeLimitReader{r: zr, n: int64(limit)}
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			return nil, err
+		}
+		if err := zr.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
+
+func (s *CompressingCrypter) maxDecompressedSize() int {
+	switch {
+	case s.MaxDecompressedSize == 0:
+		return DefaultMaxDecompressedSize
+	case s.MaxDecompressedSize < 0:
+		return -1
+	default:
+		return s.MaxDecompressedSize
+	}
+}
+
+// EncryptedSize returns an upper bound of the size of the encrypted data, since the compressed size is not known in advance.
+// It requires the inner crypter to report the encrypted size as well, otherwise [ErrSizeNotSupported] is returned.
+func (s *CompressingCrypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	inner, ok := s.inner.(interface{ EncryptedSize(int) (int, error) })
+	if !ok {
+		return 0, ErrSizeNotSupported
+	}
+
+	// the data is never stored larger than it is, plus the header byte
+	return inner.EncryptedSize(dataSize + 1)
+}
+
+// IsBypassed reports whether the inner crypter detects the data as produced in bypass mode. See [BypassDetector].
+func (s *CompressingCrypter) IsBypassed(data []byte) bool {
+	return isBypassed(s.inner, data)
+}
@@ -0,0 +1,114 @@
+package silent
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressingCrypter(t *testing.T) {
+	inner1 := MultiKeyCrypter{}
+	inner1.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c1 := NewCompressingCrypter(&inner1)
+
+	inner2 := MultiKeyCrypter{}
+	inner2.AddKey(1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+	c2 := NewCompressingCrypter(&inner2)
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		// EncryptedSize is only an upper bound, which is checked separately below
+		enc1 := struct{ Crypter }{c1}
+		enc2 := struct{ Crypter }{c2}
+
+		runCrypterSubtests(t, "c1 should decrypt self", c1, enc1)
+		runCrypterSubtests(t, "c1 should not decrypt c2", c1, enc2)
+	})
+
+	t.Run("size upper bound", func(t *testing.T) {
+		for _, text := range append(texts, bytes.Repeat([]byte("a"), 1000), []byte("Hi")) {
+			enc, err := c1.Encrypt(text)
+			RequireNoError(t, err)
+
+			size, err := c1.EncryptedSize(len(text))
+			RequireNoError(t, err)
+			RequireTrue(t, len(enc) <= size)
+
+			// incompressible data takes exactly the upper bound
+			if len(text) > 0 && len(text) < 10 {
+				RequireEqual(t, len(enc), size)
+			}
+		}
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		text := bytes.Repeat([]byte(`{"name":"John","email":"john@example.com"},`), 100)
+
+		enc, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+		RequireTrue(t, len(enc) < len(text)/4)
+
+		payload, err := inner1.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, payload[0], compressionGzip)
+
+		dec, err := c1.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, text)
+	})
+
+	t.Run("tiny values", func(t *testing.T) {
+		text := []byte("Hi")
+
+		enc, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		payload, err := inner1.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, payload, []byte("\x00Hi"))
+	})
+
+	t.Run("unknown compression", func(t *testing.T) {
+		enc, err := inner1.Encrypt([]byte("\x07Hi"))
+		RequireNoError(t, err)
+
+		_, err = c1.Decrypt(enc)
+		RequireErrorIs(t, err, ErrUnknownCompression)
+	})
+
+	t.Run("max decompressed size", func(t *testing.T) {
+		text := bytes.Repeat([]byte("a"), 1000)
+
+		enc, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		c := NewCompressingCrypter(&inner1)
+		c.MaxDecompressedSize = 999
+		_, err = c.Decrypt(enc)
+		RequireErrorIs(t, err, ErrTooLarge)
+
+		c.MaxDecompressedSize = 1000
+		dec, err := c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, text)
+
+		c.MaxDecompressedSize = -1
+		dec, err = c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, text)
+
+		// the default limit applies when the field is not set
+		bomb := bytes.Repeat([]byte{0}, DefaultMaxDecompressedSize+1)
+		enc, err = c1.Encrypt(bomb)
+		RequireNoError(t, err)
+		RequireTrue(t, len(enc) < len(bomb)/100)
+
+		_, err = c1.Decrypt(enc)
+		RequireErrorIs(t, err, ErrTooLarge)
+	})
+
+	t.Run("size not supported", func(t *testing.T) {
+		// hides EncryptedSize of the inner crypter
+		c := NewCompressingCrypter(struct{ Crypter }{&inner1})
+		_, err := c.EncryptedSize(10)
+		RequireErrorIs(t, err, ErrSizeNotSupported)
+	})
+}
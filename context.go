@@ -0,0 +1,135 @@
+package silent
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// ContextCrypter is implemented by crypters that accept a context, typically remote backends,
+// such as KMS or Vault, that need cancellation, deadlines and request-scoped tracing.
+//
+// Context-aware code paths, such as [ContextValue], prefer these methods when the bound crypter implements them,
+// and fall back to Encrypt and Decrypt otherwise. Local crypters, such as [MultiKeyCrypter], don't do any I/O,
+// so they don't implement this interface, and the context is simply ignored for them.
+type ContextCrypter interface {
+	Crypter
+	EncryptContext(ctx context.Context, data []byte) ([]byte, error)
+	DecryptContext(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// EncryptContext is like [Encrypt], but passes ctx to the bound crypter if it implements [ContextCrypter].
+func (m *crypterMapping) EncryptContext(ctx context.Context, data []byte) (res []byte, err error) {
+	c, ok := m.Crypter.(ContextCrypter)
+	if !ok {
+		return m.Encrypt(data)
+	}
+
+	if o := observer.Load(); o != nil {
+		defer observeEncrypt(o, len(data), time.Now(), &err)
+	}
+
+	return c.EncryptContext(ctx, m.addPepper(data))
+}
+
+// DecryptContext is like [Decrypt], but passes ctx to the bound crypter if it implements [ContextCrypter].
+func (m *crypterMapping) DecryptContext(ctx context.Context, data []byte) (res []byte, err error) {
+	c, ok := m.Crypter.(ContextCrypter)
+	if !ok {
+		return m.Decrypt(data)
+	}
+
+	if o := observer.Load(); o != nil {
+		defer observeDecrypt(o, len(data), time.Now(), &err)
+	}
+	if m.OnDecrypt != nil {
+		defer m.auditDecrypt(len(data), &err)
+	}
+
+	if err := m.checkBypass(data); err != nil {
+		return nil, err
+	}
+
+	res, err = c.DecryptContext(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.removePepper(res)
+}
+
+// ContextValue is an encrypted value bound to a context, which is passed to the crypter on Value and Scan.
+// The driver.Valuer and sql.Scanner interfaces don't carry a context, so this is the way to propagate
+// the context of a query to crypters implementing [ContextCrypter]:
+//
+//	_, err := db.ExecContext(ctx, "UPDATE users SET token = ? WHERE id = ?", silent.WithContext(ctx, user.Token), user.ID)
+//
+//	v := silent.WithContext[silent.EncryptedValue](ctx, nil)
+//	err := db.QueryRowContext(ctx, "SELECT token FROM users WHERE id = ?", user.ID).Scan(&v)
+//	user.Token = silent.EncryptedValue(v.Data)
+type ContextValue[T any] struct {
+	Data EncryptedValueFactory[T]
+	Ctx  context.Context
+}
+
+// WithContext creates a new [ContextValue] from the value and context.
+func WithContext[F EncryptedValueFactory[T], T any](ctx context.Context, v F) ContextValue[T] {
+	return ContextValue[T]{Data: EncryptedValueFactory[T](v), Ctx: ctx}
+}
+
+// Value is a driver.Valuer implementation. It encrypts the value, passing the context to the crypter.
+func (v ContextValue[T]) Value() (driver.Value, error) {
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v.Data) == 0 {
+		return crypter.driverValue([]byte{}), nil
+	}
+
+	encData, err := crypter.EncryptContext(v.context(), v.Data)
+	if err != nil {
+		return nil, err
+	}
+	return crypter.driverValue(encData), nil
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the value, passing the context to the crypter.
+func (v *ContextValue[T]) Scan(value interface{}) error {
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	encData, ok := scanBytes(value)
+	if !ok {
+		return fmt.Errorf("unable to scan %T into ContextValue", value)
+	}
+
+	if len(encData) == 0 {
+		v.Data = nil
+		return nil
+	}
+
+	encData, err = crypter.scannedData(encData)
+	if err != nil {
+		return err
+	}
+
+	data, err := crypter.DecryptContext(v.context(), encData)
+	if err != nil {
+		return err
+	}
+
+	v.Data = data
+	return nil
+}
+
+func (v ContextValue[T]) context() context.Context {
+	if v.Ctx == nil {
+		return context.Background()
+	}
+	return v.Ctx
+}
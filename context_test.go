@@ -0,0 +1,111 @@
+package silent
+
+import (
+	"context"
+	"testing"
+)
+
+type ctxKey struct{}
+
+// recordingCrypter is a ContextCrypter that records the contexts it receives and respects cancellation.
+type recordingCrypter struct {
+	MultiKeyCrypter
+	seen []interface{}
+}
+
+func (c *recordingCrypter) EncryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.seen = append(c.seen, ctx.Value(ctxKey{}))
+	return c.Encrypt(data)
+}
+
+func (c *recordingCrypter) DecryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.seen = append(c.seen, ctx.Value(ctxKey{}))
+	return c.Decrypt(data)
+}
+
+func TestContextValue(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	c2 := &recordingCrypter{}
+	c2.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type dummy2 struct{}
+	type EncryptedValue2 = EncryptedValueFactory[dummy2]
+	BindCrypterTo[EncryptedValue2](c2, WithPepper([]byte("pepper")))
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue2]() })
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request 1")
+
+	t.Run("context crypter", func(t *testing.T) {
+		c2.seen = nil
+
+		enc, err := WithContext(ctx, EncryptedValue2("Hello, world!")).Value()
+		RequireNoError(t, err)
+
+		dec := WithContext[EncryptedValue2](ctx, nil)
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, string(dec.Data), "Hello, world!")
+
+		RequireEqual(t, c2.seen, []interface{}{"request 1", "request 1"})
+
+		// compatible with the context-free path
+		var plain EncryptedValue2
+		RequireNoError(t, plain.Scan(enc))
+		RequireEqual(t, string(plain), "Hello, world!")
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := WithContext(ctx, EncryptedValue2("Hello, world!")).Value()
+		RequireErrorIs(t, err, context.Canceled)
+
+		enc, err := EncryptedValue2("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		dec := WithContext[EncryptedValue2](ctx, nil)
+		RequireErrorIs(t, dec.Scan(enc), context.Canceled)
+	})
+
+	t.Run("local crypter", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// the context is ignored
+		enc, err := WithContext(ctx, EncryptedValue1("Hello, world!")).Value()
+		RequireNoError(t, err)
+
+		dec := WithContext[EncryptedValue1](ctx, nil)
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, string(dec.Data), "Hello, world!")
+	})
+
+	t.Run("empty and nil", func(t *testing.T) {
+		enc, err := ContextValue[dummy2]{}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, enc, interface{}([]byte{}))
+
+		dec := ContextValue[dummy2]{Data: EncryptedValue2("x")}
+		RequireNoError(t, dec.Scan(nil))
+		RequireTrue(t, dec.Data == nil)
+
+		// nil context is treated as background
+		enc, err = ContextValue[dummy2]{Data: EncryptedValue2("Hello, world!")}.Value()
+		RequireNoError(t, err)
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, string(dec.Data), "Hello, world!")
+	})
+}
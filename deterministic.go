@@ -0,0 +1,78 @@
+package silent
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeterministicCrypter is a [Crypter] implementation based on AES-256-SIV (RFC 5297).
+// Unlike other crypters, it produces identical encrypted data for identical plaintexts encrypted with the same key.
+// This allows to look up rows by an exact value of an encrypted column, e.g. WHERE email = ?,
+// by encrypting the value being searched for.
+//
+// This comes at a cost: anyone who can see the encrypted data can tell which rows have equal values,
+// and can count how often each value occurs. Use it only for columns that need equality lookups,
+// and prefer randomized crypters, such as [MultiKeyCrypter], for everything else.
+//
+// Like MultiKeyCrypter, it supports multiple keys: the last added key is used for encryption,
+// and the key ID embedded in the encrypted data is used to select the key for decryption.
+// Keep in mind that lookups only find values encrypted with the current key, so key rotation
+// requires re-encrypting the column.
+//
+// By default, equal plaintexts produce equal encrypted data across all columns that share the key,
+// so it's possible to tell that e.g. a user's email and their recovery email are the same.
+// Setting FieldID scopes the encryption to a column, without the need for separate keys.
+type DeterministicCrypter struct {
+	keyring aeadKeyring
+
+	// FieldID is mixed into the synthetic IV, so equal plaintexts produce different encrypted data
+	// in fields with different IDs, while lookups within a field still work. Bind a separate crypter
+	// with a unique FieldID, such as "users.email", to each column. Data can only be decrypted with the same FieldID,
+	// so it must not be changed once there is data encrypted with it.
+	FieldID string
+}
+
+// NewDeterministicCrypter creates a new DeterministicCrypter. Keys must be added with [DeterministicCrypter.AddKey] before use.
+func NewDeterministicCrypter() *DeterministicCrypter {
+	return &DeterministicCrypter{}
+}
+
+// AddKey adds a new key to the crypter.
+// The keyID must be unique and the key must be exactly 32 bytes long.
+func (c *DeterministicCrypter) AddKey(keyID uint32, key []byte) {
+	c.keyring.addKey(keyID, key, newDeterministicSIV)
+}
+
+// Encrypt encrypts the data using the last added key.
+func (c *DeterministicCrypter) Encrypt(data []byte) ([]byte, error) {
+	return c.keyring.encrypt(data, nil, []byte(c.FieldID)) // SIV uses no nonce
+}
+
+// Decrypt decrypts the data.
+// The key is automatically selected based on the key ID embedded in the data.
+// Data encrypted with a different FieldID fails authentication.
+func (c *DeterministicCrypter) Decrypt(data []byte) ([]byte, error) {
+	return c.keyring.decrypt(data, []byte(c.FieldID))
+}
+
+// EncryptedSize returns the size of the encrypted data.
+func (c *DeterministicCrypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	return aeadHeaderSize + dataSize + 16, nil
+}
+
+// newDeterministicSIV expands a 32-byte key into the 64-byte key required by AES-256-SIV.
+func newDeterministicSIV(key []byte) (cipher.AEAD, error) {
+	sivKey := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("silent siv")), sivKey); err != nil {
+		return nil, err
+	}
+
+	return newSIV(sivKey)
+}
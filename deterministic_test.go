@@ -0,0 +1,194 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestDeterministicCrypter(t *testing.T) {
+	c1 := NewDeterministicCrypter()
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	// same as c1, but with additional key
+	c2 := NewDeterministicCrypter()
+	c2.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c2.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+	// same key id as in c1, but the key itself is different
+	c1broken := NewDeterministicCrypter()
+	c1broken.AddKey(0x1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "c1 should decrypt self", c1, c1)
+		runCrypterSubtests(t, "c1 should not decrypt c2", c1, c2)
+		runCrypterSubtests(t, "c1 should not decrypt c1broken", c1, c1broken)
+
+		runCrypterSubtests(t, "c2 should decrypt self", c2, c2)
+		runCrypterSubtests(t, "c2 should decrypt c1", c2, c1)
+		runCrypterSubtests(t, "c2 should not decrypt c1broken", c2, c1broken)
+	})
+
+	t.Run("encrypt", func(t *testing.T) {
+		text := []byte("Hello, World!")
+		encryptedText1, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		if bytes.Contains(encryptedText1, text) {
+			t.Fatalf("encrypted text contains plaintext")
+		}
+
+		// stable across calls
+		encryptedText2, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+		RequireEqual(t, encryptedText1, encryptedText2)
+
+		// different plaintexts don't collide
+		encryptedText3, err := c1.Encrypt([]byte("Hello, World?"))
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(encryptedText1, encryptedText3))
+
+		// different keys produce different data
+		encryptedText4, err := c1broken.Encrypt(text)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(encryptedText1, encryptedText4))
+	})
+
+	t.Run("decrypt errors", func(t *testing.T) {
+		encryptedText, err := c2.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = c1.Decrypt(encryptedText)
+		RequireErrorIs(t, err, ErrUnknownKey)
+
+		// the key id is authenticated
+		tampered := bytes.Clone(encryptedText)
+		tampered[1] = 0x1
+		_, err = c2.Decrypt(tampered)
+
+		var decErr *DecryptError
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+		_, err = c2.Decrypt(encryptedText[:10])
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindCorrupt)
+	})
+
+	t.Run("field id", func(t *testing.T) {
+		email := NewDeterministicCrypter()
+		email.FieldID = "users.email"
+		email.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		recovery := NewDeterministicCrypter()
+		recovery.FieldID = "users.recovery_email"
+		recovery.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		runCrypterSubtests(t, "email should decrypt self", email, email)
+		runCrypterSubtests(t, "email should not decrypt recovery", email, recovery)
+		runCrypterSubtests(t, "email should not decrypt c1", email, c1)
+
+		text := []byte("john@example.com")
+
+		// equal within a field
+		enc1, err := email.Encrypt(text)
+		RequireNoError(t, err)
+		enc2, err := email.Encrypt(text)
+		RequireNoError(t, err)
+		RequireEqual(t, enc1, enc2)
+
+		// but different across fields
+		enc3, err := recovery.Encrypt(text)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(enc1, enc3))
+
+		enc4, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(enc1, enc4))
+
+		_, err = recovery.Decrypt(enc1)
+		var decErr *DecryptError
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+	})
+
+	t.Run("rfc 5297 vector", func(t *testing.T) {
+		decodeHex := func(s string) []byte {
+			res, err := hex.DecodeString(s)
+			RequireNoError(t, err)
+			return res
+		}
+
+		key := decodeHex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+		ad := decodeHex("101112131415161718191a1b1c1d1e1f2021222324252627")
+		plaintext := decodeHex("112233445566778899aabbccddee")
+		expected := decodeHex("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+		aead, err := newSIV(key)
+		RequireNoError(t, err)
+
+		res := aead.Seal(nil, nil, plaintext, ad)
+		RequireEqual(t, res, expected)
+
+		dec, err := aead.Open(nil, nil, res, ad)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, plaintext)
+	})
+
+	t.Run("rfc 5297 nonce-based vector", func(t *testing.T) {
+		decodeHex := func(s string) []byte {
+			res, err := hex.DecodeString(s)
+			RequireNoError(t, err)
+			return res
+		}
+
+		// A.2 uses two associated data components, followed by the nonce, as the multiple components case of S2V
+		key := decodeHex("7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f")
+		ad1 := decodeHex("00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100")
+		ad2 := decodeHex("102030405060708090a0")
+		nonce := decodeHex("09f911029d74e35bd84156c5635688c0")
+		plaintext := decodeHex("7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553")
+		expected := decodeHex("7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d")
+
+		aead, err := newSIV(key)
+		RequireNoError(t, err)
+		s := aead.(*sivAEAD)
+
+		v := s.s2v(plaintext, ad1, ad2, nonce)
+		RequireEqual(t, v[:], expected[:16])
+
+		res := make([]byte, len(plaintext))
+		s.xorKeyStream(res, plaintext, v)
+		RequireEqual(t, res, expected[16:])
+	})
+
+	t.Run("rfc 4493 cmac vectors", func(t *testing.T) {
+		decodeHex := func(s string) []byte {
+			res, err := hex.DecodeString(s)
+			RequireNoError(t, err)
+			return res
+		}
+
+		// the first half of the key is used for CMAC
+		key := decodeHex("2b7e151628aed2a6abf7158809cf4f3c" + "00000000000000000000000000000000")
+		message := decodeHex("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+
+		aead, err := newSIV(key)
+		RequireNoError(t, err)
+		s := aead.(*sivAEAD)
+
+		for _, tc := range []struct {
+			size     int
+			expected string
+		}{
+			{0, "bb1d6929e95937287fa37d129b756746"},  // empty
+			{16, "070a16b46b4d4144f79bdd9dd04a287c"}, // one full block
+			{40, "dfa66747de9ae63030ca32611497c827"}, // partial last block
+			{64, "51f0bebf7e3b9d92fc49741779363cfe"}, // multiple full blocks
+		} {
+			mac := s.cmac(message[:tc.size])
+			RequireEqual(t, hex.EncodeToString(mac[:]), tc.expected)
+		}
+	})
+}
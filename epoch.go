@@ -0,0 +1,144 @@
+package silent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/minio/sio"
+	"golang.org/x/crypto/hkdf"
+)
+
+const epochHeaderSize = 9 // version + epoch number
+
+// EpochCrypter is a [Crypter] implementation that rotates keys automatically based on time.
+// Data is encrypted with a subkey derived from the master key and the number of the current epoch.
+// The epoch number is embedded in the encrypted data, so the same subkey can be derived again on decryption.
+// This means that all data ever encrypted by the crypter can be decrypted as long as the master key is known.
+type EpochCrypter struct {
+	master []byte
+	epoch  time.Duration
+
+	sioConfigTemplate sio.Config
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	// Rand is the source of randomness for the nonces generated by sio. If nil, crypto/rand.Reader is used.
+	// Like Now, it's meant for tests; a predictable source outside of tests breaks the security of encryption.
+	Rand io.Reader
+}
+
+// NewEpochCrypter creates a new EpochCrypter with the given master key and epoch duration, e.g. 24*time.Hour.
+// The master key must be at least 32 bytes long.
+func NewEpochCrypter(master []byte, epoch time.Duration) *EpochCrypter {
+	if len(master) < 32 {
+		panic("misconfiguration: master key must be at least 32 bytes")
+	}
+
+	if epoch <= 0 {
+		panic("misconfiguration: epoch duration must be positive")
+	}
+
+	return &EpochCrypter{
+		master: master,
+		epoch:  epoch,
+		sioConfigTemplate: sio.Config{
+			MinVersion: sio.Version20,
+		},
+	}
+}
+
+// Encrypt encrypts the data using the subkey of the current epoch.
+func (s *EpochCrypter) Encrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	size, err := s.EncryptedSize(len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	epoch := uint64(now().UnixNano() / int64(s.epoch))
+
+	key, err := s.deriveKey(epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(size)
+
+	buf.WriteByte(1)
+	buf.Write(binary.LittleEndian.AppendUint64(nil, epoch))
+
+	sioConfig := s.sioConfigTemplate
+	sioConfig.Key = key
+	sioConfig.Rand = s.Rand
+	if _, err := sio.Encrypt(&buf, bytes.NewReader(data), sioConfig); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts the data using the subkey of the epoch embedded in the data.
+// Errors are reported as [DecryptError], same as with [MultiKeyCrypter].
+func (s *EpochCrypter) Decrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	version := data[0]
+	if version != 1 {
+		return nil, &DecryptError{Kind: KindUnsupportedVersion, Version: version, Cause: ErrUnsupportedVersion}
+	}
+
+	if len(data) < epochHeaderSize {
+		return nil, &DecryptError{Kind: KindCorrupt, Version: version, Cause: truncatedError(io.ErrUnexpectedEOF)}
+	}
+
+	key, err := s.deriveKey(binary.LittleEndian.Uint64(data[1:epochHeaderSize]))
+	if err != nil {
+		return nil, err
+	}
+
+	sioConfig := s.sioConfigTemplate
+	sioConfig.Key = key
+	res, err := sio.DecryptBuffer(make([]byte, 0, len(data)), data[epochHeaderSize:], sioConfig)
+	if err != nil {
+		return nil, sioDecryptError(err, version, keyRef{})
+	}
+	return res, nil
+}
+
+// EncryptedSize returns the size of the encrypted data.
+func (s *EpochCrypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	res, err := sio.EncryptedSize(uint64(dataSize))
+	if err != nil {
+		return 0, err
+	}
+	return int(res) + epochHeaderSize, nil
+}
+
+func (s *EpochCrypter) deriveKey(epoch uint64) ([]byte, error) {
+	info := binary.LittleEndian.AppendUint64([]byte("silent epoch "), epoch)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, s.master, nil, info), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
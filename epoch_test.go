@@ -0,0 +1,83 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEpochCrypter(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	c1 := NewEpochCrypter(DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), day)
+	c1.Now = func() time.Time { return start }
+
+	// same master, but a different epoch
+	c1later := NewEpochCrypter(DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), day)
+	c1later.Now = func() time.Time { return start.Add(3 * day) }
+
+	// same epoch, but a different master
+	c2 := NewEpochCrypter(DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="), day)
+	c2.Now = func() time.Time { return start }
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "c1 should decrypt self", c1, c1)
+		runCrypterSubtests(t, "c1 should decrypt c1later", c1, c1later)
+		runCrypterSubtests(t, "c1later should decrypt c1", c1later, c1)
+		runCrypterSubtests(t, "c1 should not decrypt c2", c1, c2)
+		runCrypterSubtests(t, "c2 should not decrypt c1later", c2, c1later)
+	})
+
+	t.Run("epochs", func(t *testing.T) {
+		text := []byte("Hello, World!")
+
+		enc1, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		enc2, err := c1later.Encrypt(text)
+		RequireNoError(t, err)
+
+		epoch1 := binary.LittleEndian.Uint64(enc1[1:epochHeaderSize])
+		epoch2 := binary.LittleEndian.Uint64(enc2[1:epochHeaderSize])
+		RequireEqual(t, epoch2-epoch1, uint64(3))
+
+		// different epochs must use different subkeys
+		forged := bytes.Clone(enc2)
+		binary.LittleEndian.PutUint64(forged[1:epochHeaderSize], epoch1)
+		_, err = c1.Decrypt(forged)
+		RequireErrorIs(t, err, ErrAuthentication)
+	})
+
+	t.Run("decrypt errors", func(t *testing.T) {
+		enc, err := c1.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		tampered := bytes.Clone(enc)
+		tampered[len(tampered)-1] ^= 1
+
+		for _, tc := range []struct {
+			name string
+			data []byte
+			kind DecryptErrorKind
+			is   error
+		}{
+			{"unsupported version", []byte{7, 1, 2, 3}, KindUnsupportedVersion, ErrUnsupportedVersion},
+			{"short header", enc[:5], KindCorrupt, ErrTruncated},
+			{"truncated body", enc[:len(enc)-10], KindCorrupt, ErrTruncated},
+			{"tampered", tampered, KindAuthFailed, ErrAuthentication},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := c1.Decrypt(tc.data)
+				RequireErrorIs(t, err, tc.is)
+
+				var decErr *DecryptError
+				RequireTrue(t, errors.As(err, &decErr))
+				RequireEqual(t, decErr.Kind, tc.kind)
+				RequireEqual(t, decErr.Version, tc.data[0])
+			})
+		}
+	})
+}
@@ -75,7 +75,8 @@ func Example_databaseEncryptAndDecrypt() {
 
 	fmt.Println("Decrypted users:")
 	for _, u := range users {
-		fmt.Printf("%+v\n", u)
+		// Token is redacted when printed, so convert it explicitly
+		fmt.Printf("{Username:%s Token:%s}\n", u.Username, []byte(u.Token))
 	}
 	fmt.Println("")
 
@@ -145,7 +146,8 @@ func Example_jsonEncryptAndDecrypt() {
 	// Print the decrypted users
 	fmt.Println("Decrypted users:")
 	for _, u := range decryptedUsers {
-		fmt.Printf("%+v\n", u)
+		// Token is redacted when printed, so convert it explicitly
+		fmt.Printf("{Username:%s Token:%s}\n", u.Username, []byte(u.Token))
 	}
 }
 
@@ -0,0 +1,62 @@
+package silent
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+)
+
+// GCMCrypter is a [Crypter] implementation based on AES-256-GCM with random 12-byte nonces.
+// Compared to [MultiKeyCrypter], it has a smaller overhead and is faster for short values, such as tokens,
+// but it is not suitable for streaming.
+//
+// Like MultiKeyCrypter, it supports multiple keys: the last added key is used for encryption,
+// and the key ID embedded in the encrypted data is used to select the key for decryption.
+type GCMCrypter struct {
+	keyring aeadKeyring
+
+	// Rand is the source of random nonces. If nil, crypto/rand.Reader is used.
+	// It's meant for reproducible ciphertext in tests, or for routing randomness through a FIPS module.
+	// A predictable source outside of tests makes nonces repeat, which breaks the security of encryption.
+	Rand io.Reader
+}
+
+// NewGCMCrypter creates a new GCMCrypter. Keys must be added with [GCMCrypter.AddKey] before use.
+func NewGCMCrypter() *GCMCrypter {
+	return &GCMCrypter{}
+}
+
+// AddKey adds a new key to the crypter.
+// The keyID must be unique and the key must be exactly 32 bytes long.
+func (c *GCMCrypter) AddKey(keyID uint32, key []byte) {
+	c.keyring.addKey(keyID, key, newGCM)
+}
+
+// Encrypt encrypts the data using the last added key.
+func (c *GCMCrypter) Encrypt(data []byte) ([]byte, error) {
+	return c.keyring.encrypt(data, c.Rand, nil)
+}
+
+// Decrypt decrypts the data.
+// The key is automatically selected based on the key ID embedded in the data.
+func (c *GCMCrypter) Decrypt(data []byte) ([]byte, error) {
+	return c.keyring.decrypt(data, nil)
+}
+
+// EncryptedSize returns the size of the encrypted data.
+func (c *GCMCrypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	return aeadHeaderSize + 12 + dataSize + 16, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
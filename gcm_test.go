@@ -0,0 +1,97 @@
+package silent
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestGCMCrypter(t *testing.T) {
+	c1 := NewGCMCrypter()
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	// same as c1, but with additional key
+	c2 := NewGCMCrypter()
+	c2.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c2.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+	// same key id as in c1, but the key itself is different
+	c1broken := NewGCMCrypter()
+	c1broken.AddKey(0x1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "c1 should decrypt self", c1, c1)
+		runCrypterSubtests(t, "c1 should not decrypt c2", c1, c2)
+		runCrypterSubtests(t, "c1 should not decrypt c1broken", c1, c1broken)
+
+		runCrypterSubtests(t, "c2 should decrypt self", c2, c2)
+		runCrypterSubtests(t, "c2 should decrypt c1", c2, c1)
+		runCrypterSubtests(t, "c2 should not decrypt c1broken", c2, c1broken)
+	})
+
+	t.Run("encrypt", func(t *testing.T) {
+		text := []byte("Hello, World!")
+		encryptedText1, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		if bytes.Contains(encryptedText1, text) {
+			t.Fatalf("encrypted text contains plaintext")
+		}
+
+		encryptedText2, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(encryptedText1, encryptedText2))
+	})
+
+	t.Run("rand", func(t *testing.T) {
+		c := NewGCMCrypter()
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.Rand = bytes.NewReader(make([]byte, 12))
+
+		encryptedText, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		RequireEqual(t, encryptedText[aeadHeaderSize:aeadHeaderSize+12], make([]byte, 12))
+
+		text, err := c1.Decrypt(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, string(text), "Hello, World!")
+
+		// the reader is exhausted
+		_, err = c.Encrypt([]byte("Hello, World!"))
+		RequireErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("decrypt errors", func(t *testing.T) {
+		encryptedText, err := c2.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = c1.Decrypt(encryptedText)
+		RequireErrorIs(t, err, ErrUnknownKey)
+
+		// the key id is authenticated
+		tampered := bytes.Clone(encryptedText)
+		tampered[1] = 0x1
+		_, err = c2.Decrypt(tampered)
+
+		var decErr *DecryptError
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+		_, err = c2.Decrypt(encryptedText[:10])
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindCorrupt)
+		RequireErrorIs(t, err, ErrTruncated)
+	})
+
+	t.Run("key size", func(t *testing.T) {
+		for _, size := range []int{16, 33, 64} {
+			func() {
+				defer func() {
+					RequireTrue(t, recover() != nil)
+				}()
+				NewGCMCrypter().AddKey(0x1, make([]byte, size))
+			}()
+		}
+	})
+}
@@ -2,12 +2,20 @@ module github.com/destel/silent
 
 go 1.21
 
-require github.com/minio/sio v0.4.0
+require (
+	github.com/minio/sio v0.4.0
+	golang.org/x/crypto v0.33.0
+)
 
-require github.com/proullon/ramsql v0.1.3 // tests only
+require (
+	github.com/proullon/ramsql v0.1.3 // tests and silenttest only
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // tests only
+	go.mongodb.org/mongo-driver/v2 v2.8.2 // tests only
+	gopkg.in/yaml.v3 v3.0.1 // tests only
+)
 
 require (
-	golang.org/x/crypto v0.23.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/exp v0.0.0-20230713183714-613f0c0eb8a1 // indirect
-	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 )
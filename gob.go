@@ -0,0 +1,37 @@
+package silent
+
+// GobEncode implements the gob.GobEncoder interface. It encrypts the value using the bound crypter.
+// Empty values are encoded as empty data.
+func (v EncryptedValueFactory[T]) GobEncode() ([]byte, error) {
+	if len(v) == 0 {
+		return []byte{}, nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	return crypter.Encrypt(v)
+}
+
+// GobDecode implements the gob.GobDecoder interface. It decrypts the value using the bound crypter.
+func (v *EncryptedValueFactory[T]) GobDecode(data []byte) error {
+	if len(data) == 0 {
+		*v = nil
+		return nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	res, err := crypter.Decrypt(data)
+	if err != nil {
+		return err
+	}
+
+	*v = res
+	return nil
+}
@@ -0,0 +1,72 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestEncryptedValueGob(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type user struct {
+		Username string
+		Token    EncryptedValue1
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, text := range texts {
+			orig := user{Username: "john", Token: EncryptedValue1(text)}
+
+			var buf bytes.Buffer
+			RequireNoError(t, gob.NewEncoder(&buf).Encode(orig))
+
+			if len(text) > 0 && bytes.Contains(buf.Bytes(), text) {
+				t.Fatalf("encoded stream contains plaintext")
+			}
+
+			var dec user
+			RequireNoError(t, gob.NewDecoder(&buf).Decode(&dec))
+			RequireEqual(t, dec.Username, orig.Username)
+			RequireEqual(t, string(dec.Token), string(orig.Token))
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		users := []user{
+			{Username: "john", Token: EncryptedValue1("some token")},
+			{Username: "jane"},
+			{Username: "jack", Token: EncryptedValue1("another token")},
+		}
+
+		var buf bytes.Buffer
+		enc := gob.NewEncoder(&buf)
+		for _, u := range users {
+			RequireNoError(t, enc.Encode(u))
+		}
+
+		dec := gob.NewDecoder(&buf)
+		for _, expected := range users {
+			var u user
+			RequireNoError(t, dec.Decode(&u))
+			RequireEqual(t, u.Username, expected.Username)
+			RequireEqual(t, string(u.Token), string(expected.Token))
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		enc, err := EncryptedValue1("Hello, World!").GobEncode()
+		RequireNoError(t, err)
+
+		enc[len(enc)-1] ^= 1
+
+		var dec EncryptedValue1
+		RequireError(t, dec.GobDecode(enc))
+	})
+}
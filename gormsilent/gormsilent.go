@@ -0,0 +1,122 @@
+// Package gormsilent provides a GORM serializer that encrypts model fields with a [silent.Crypter].
+// It lives in a separate module to keep GORM out of the core module dependencies.
+//
+// The serializer allows to encrypt fields without changing their types to [silent.EncryptedValue].
+// Register it once at the start of the application:
+//
+//	schema.RegisterSerializer("silent", gormsilent.New(&crypter))
+//
+// and mark the fields that should be encrypted:
+//
+//	type User struct {
+//		ID    uint
+//		Token string `gorm:"serializer:silent"`
+//	}
+//
+// Fields of type string and []byte (including named types based on them) are supported.
+// Empty values are stored as empty, the same way as with [silent.EncryptedValue].
+// If the crypter implements [silent.ContextCrypter], it receives the context of the GORM statement.
+package gormsilent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+
+	"github.com/destel/silent"
+)
+
+// Serializer is a GORM serializer that encrypts and decrypts field values with a crypter.
+type Serializer struct {
+	crypter silent.Crypter
+}
+
+var _ schema.SerializerInterface = (*Serializer)(nil)
+
+// New creates a Serializer that uses the provided crypter.
+func New(crypter silent.Crypter) *Serializer {
+	if crypter == nil {
+		panic("misconfiguration: crypter is nil")
+	}
+
+	return &Serializer{crypter: crypter}
+}
+
+// Scan decrypts the database value and sets it to the field.
+func (s *Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var encData []byte
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		encData = v
+	case string:
+		encData = []byte(v)
+	default:
+		return fmt.Errorf("unable to scan %T into field %s", dbValue, field.Name)
+	}
+
+	var data []byte
+	if len(encData) > 0 {
+		var err error
+		data, err = s.decrypt(ctx, encData)
+		if err != nil {
+			return err
+		}
+	}
+
+	fieldValue := reflect.New(field.FieldType).Elem()
+	switch {
+	case fieldValue.Kind() == reflect.String:
+		fieldValue.SetString(string(data))
+	case isBytes(field.FieldType):
+		fieldValue.SetBytes(data)
+	default:
+		return fmt.Errorf("unsupported type %s of field %s", field.FieldType, field.Name)
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue)
+	return nil
+}
+
+// Value encrypts the field value and returns a byte slice suitable for database storage.
+func (s *Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	v := reflect.ValueOf(fieldValue)
+
+	var data []byte
+	switch {
+	case !v.IsValid():
+		// nil is stored as empty
+	case v.Kind() == reflect.String:
+		data = []byte(v.String())
+	case isBytes(v.Type()):
+		data = v.Bytes()
+	default:
+		return nil, fmt.Errorf("unsupported type %T of field %s", fieldValue, field.Name)
+	}
+
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+
+	return s.encrypt(ctx, data)
+}
+
+func (s *Serializer) encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if c, ok := s.crypter.(silent.ContextCrypter); ok {
+		return c.EncryptContext(ctx, data)
+	}
+	return s.crypter.Encrypt(data)
+}
+
+func (s *Serializer) decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if c, ok := s.crypter.(silent.ContextCrypter); ok {
+		return c.DecryptContext(ctx, data)
+	}
+	return s.crypter.Decrypt(data)
+}
+
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
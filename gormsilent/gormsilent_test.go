@@ -0,0 +1,139 @@
+package gormsilent_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"testing"
+
+	_ "github.com/proullon/ramsql/driver"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+
+	"github.com/destel/silent"
+	"github.com/destel/silent/gormsilent"
+)
+
+type User struct {
+	ID       uint
+	Username string
+	Token    string `gorm:"serializer:silent"`
+	Secret   []byte `gorm:"serializer:silent"`
+}
+
+type Note struct {
+	ID   uint
+	Text string `gorm:"serializer:silentctx"`
+}
+
+type ctxKey struct{}
+
+// contextCrypter records the values of ctxKey it receives.
+type contextCrypter struct {
+	*silent.MultiKeyCrypter
+	seen []interface{}
+}
+
+func (c *contextCrypter) EncryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	c.seen = append(c.seen, ctx.Value(ctxKey{}))
+	return c.Encrypt(data)
+}
+
+func (c *contextCrypter) DecryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	c.seen = append(c.seen, ctx.Value(ctxKey{}))
+	return c.Decrypt(data)
+}
+
+func TestSerializer(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString("Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crypter := silent.MultiKeyCrypter{}
+	crypter.AddKey(1, key)
+	schema.RegisterSerializer("silent", gormsilent.New(&crypter))
+
+	ctxCrypter := &contextCrypter{MultiKeyCrypter: &crypter}
+	schema.RegisterSerializer("silentctx", gormsilent.New(ctxCrypter))
+
+	sqlDB, err := sql.Open("ramsql", "TestSerializer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tables are created without primary keys, since ramsql index lookups fail checkptr validation under -race.
+	for _, q := range []string{
+		`CREATE TABLE users (id BIGSERIAL, username TEXT, token BYTEA, secret BYTEA)`,
+		`CREATE TABLE notes (id BIGSERIAL, text BYTEA)`,
+	} {
+		if _, err := sqlDB.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users := []User{
+		{Username: "john", Token: "some token", Secret: []byte("some secret")},
+		{Username: "jane"},
+	}
+	for i := range users {
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("encrypted at rest", func(t *testing.T) {
+		var token []byte
+		if err := sqlDB.QueryRow(`SELECT token FROM users WHERE username = 'john'`).Scan(&token); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(token) == 0 || bytes.Contains(token, []byte("some token")) {
+			t.Fatalf("token is not encrypted: %q", token)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, expected := range users {
+			var u User
+			if err := db.First(&u, expected.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if u.Username != expected.Username || u.Token != expected.Token || !bytes.Equal(u.Secret, expected.Secret) {
+				t.Fatalf("expected %+v, got %+v", expected, u)
+			}
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxKey{}, "request 1")
+
+		note := Note{Text: "some text"}
+		if err := db.WithContext(ctx).Create(&note).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		var n Note
+		if err := db.WithContext(ctx).First(&n, note.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if n.Text != note.Text {
+			t.Fatalf("expected %q, got %q", note.Text, n.Text)
+		}
+
+		if len(ctxCrypter.seen) != 2 || ctxCrypter.seen[0] != "request 1" || ctxCrypter.seen[1] != "request 1" {
+			t.Fatalf("context is not propagated: %v", ctxCrypter.seen)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package silent
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrInvalidInt64 = errors.New("encrypted int64 must be 8 bytes long")
+
+// EncryptedInt64Factory is a generic type factory for encrypted integers, such as salaries or balances.
+// The integer is serialized to 8 bytes in big-endian order, and the result is encrypted with the crypter
+// bound to EncryptedValueFactory[T]. As with [EncryptedValueFactory], T is a unique dummy type used to select the crypter.
+//
+// The encryption is NOT order-preserving, so the database can't compare, sum, or range-query encrypted values,
+// e.g. WHERE salary > ?. That's deliberate: order-preserving encryption leaks the order of values, and often
+// their approximate magnitude, to anyone who can see the column.
+//
+// Unlike other types, zero is encrypted as any other value, so the stored data doesn't reveal which values are zero.
+// NULL or empty values are read as zero.
+type EncryptedInt64Factory[T any] int64
+
+// EncryptedInt64 is a built-in type that uses the same crypter as [EncryptedValue].
+type EncryptedInt64 = EncryptedInt64Factory[dummy]
+
+// String returns a redacted representation of the EncryptedInt64. See [EncryptedValueFactory.String].
+// Convert it to int64 to access the plaintext.
+func (v EncryptedInt64Factory[T]) String() string {
+	return "EncryptedInt64(<redacted>)"
+}
+
+// GoString returns the same redacted representation as [EncryptedInt64Factory.String].
+func (v EncryptedInt64Factory[T]) GoString() string {
+	return v.String()
+}
+
+// Format is a fmt.Formatter implementation. Since EncryptedInt64 is an integer, verbs such as %d would print
+// the plaintext without it, so all verbs print the same redacted representation as [EncryptedInt64Factory.String].
+func (v EncryptedInt64Factory[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, v.String())
+}
+
+// MarshalJSON encrypts the integer and marshals it the same way as [EncryptedValueFactory.MarshalJSON].
+func (v EncryptedInt64Factory[T]) MarshalJSON() ([]byte, error) {
+	return v.encode().MarshalJSON()
+}
+
+// UnmarshalJSON decrypts the integer from JSON.
+func (v *EncryptedInt64Factory[T]) UnmarshalJSON(data []byte) error {
+	var enc EncryptedValueFactory[T]
+	if err := enc.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	return v.decode(enc)
+}
+
+// Value is a driver.Valuer implementation. It encrypts the integer the same way as [EncryptedValueFactory.Value].
+func (v EncryptedInt64Factory[T]) Value() (driver.Value, error) {
+	return v.encode().Value()
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the integer from the database.
+func (v *EncryptedInt64Factory[T]) Scan(value interface{}) error {
+	var enc EncryptedValueFactory[T]
+	if err := enc.Scan(value); err != nil {
+		return err
+	}
+
+	return v.decode(enc)
+}
+
+func (v EncryptedInt64Factory[T]) encode() EncryptedValueFactory[T] {
+	return binary.BigEndian.AppendUint64(nil, uint64(v))
+}
+
+func (v *EncryptedInt64Factory[T]) decode(data []byte) error {
+	switch len(data) {
+	case 0:
+		*v = 0
+		return nil
+	case 8:
+		*v = EncryptedInt64Factory[T](binary.BigEndian.Uint64(data))
+		return nil
+	default:
+		return ErrInvalidInt64
+	}
+}
@@ -0,0 +1,74 @@
+package silent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedInt64(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedSalary = EncryptedInt64Factory[dummy1]
+	BindCrypterTo[EncryptedValueFactory[dummy1]](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummy1]]() })
+
+	values := []int64{0, 1, -1, 125000, -987654321, math.MaxInt64, math.MinInt64}
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, n := range values {
+			enc, err := json.Marshal(EncryptedSalary(n))
+			RequireNoError(t, err)
+			RequireTrue(t, string(enc) != `""`)
+
+			dec := EncryptedSalary(42)
+			RequireNoError(t, json.Unmarshal(enc, &dec))
+			RequireEqual(t, int64(dec), n)
+		}
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		for _, n := range values {
+			enc, err := EncryptedSalary(n).Value()
+			RequireNoError(t, err)
+
+			// zero is encrypted as any other value
+			raw, err := c1.Decrypt(enc.([]byte))
+			RequireNoError(t, err)
+			RequireEqual(t, len(raw), 8)
+
+			dec := EncryptedSalary(42)
+			RequireNoError(t, dec.Scan(enc))
+			RequireEqual(t, int64(dec), n)
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		dec := EncryptedSalary(42)
+		RequireNoError(t, dec.Scan(nil))
+		RequireEqual(t, int64(dec), int64(0))
+
+		dec = EncryptedSalary(42)
+		RequireNoError(t, json.Unmarshal([]byte(`null`), &dec))
+		RequireEqual(t, int64(dec), int64(0))
+	})
+
+	t.Run("invalid length", func(t *testing.T) {
+		enc, err := EncryptedValueFactory[dummy1]("1234").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedSalary
+		RequireErrorIs(t, dec.Scan(enc), ErrInvalidInt64)
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		for _, format := range []string{"%v", "%+v", "%s", "%#v", "%d", "%x", "%08d"} {
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, EncryptedSalary(125000)), "125"))
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, EncryptedSalary(125000)), "1e848"))
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package silent
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EncryptedJSONFactory is a generic type factory for types that encrypt a whole Go value, such as a struct, as one blob.
+// The value is marshaled to JSON, and the result is encrypted with the crypter bound to EncryptedValueFactory[D].
+// As with [EncryptedValueFactory], D is a unique dummy type used to select the crypter:
+//
+//	type dummy1 struct{} // this won't be used in your code
+//	type EncryptedProfile = EncryptedJSONFactory[PaymentProfile, dummy1]
+//
+//	BindCrypterTo[EncryptedValueFactory[dummy1]](&crypter)
+type EncryptedJSONFactory[T any, D any] struct {
+	Data T
+}
+
+// EncryptedJSON is like [EncryptedJSONFactory], but uses the same crypter as [EncryptedValue].
+type EncryptedJSON[T any] EncryptedJSONFactory[T, dummy]
+
+// String returns a redacted representation of the EncryptedJSON. See [EncryptedValueFactory.String].
+// Access the Data field to get the plaintext.
+func (v EncryptedJSONFactory[T, D]) String() string {
+	return "EncryptedJSON(<redacted>)"
+}
+
+// GoString returns the same redacted representation as [EncryptedJSONFactory.String].
+func (v EncryptedJSONFactory[T, D]) GoString() string {
+	return v.String()
+}
+
+// Format is a fmt.Formatter implementation. Since Data is exported, verbs such as %+v or %d would print
+// the plaintext fields without it, so all verbs print the same redacted representation as [EncryptedJSONFactory.String].
+func (v EncryptedJSONFactory[T, D]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, v.String())
+}
+
+// MarshalJSON marshals the data to JSON, encrypts it, and marshals the result the same way as [EncryptedValueFactory.MarshalJSON].
+func (v EncryptedJSONFactory[T, D]) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(v.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptedValueFactory[D](data).MarshalJSON()
+}
+
+// UnmarshalJSON decrypts the data from JSON. An empty or null value results in the zero value of T.
+func (v *EncryptedJSONFactory[T, D]) UnmarshalJSON(data []byte) error {
+	var enc EncryptedValueFactory[D]
+	if err := enc.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	return v.setData(enc)
+}
+
+// Value is a driver.Valuer implementation. It marshals the data to JSON and encrypts it.
+func (v EncryptedJSONFactory[T, D]) Value() (driver.Value, error) {
+	data, err := json.Marshal(v.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncryptedValueFactory[D](data).Value()
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the value from the database. NULL results in the zero value of T.
+func (v *EncryptedJSONFactory[T, D]) Scan(value interface{}) error {
+	var enc EncryptedValueFactory[D]
+	if err := enc.Scan(value); err != nil {
+		return err
+	}
+
+	return v.setData(enc)
+}
+
+func (v *EncryptedJSONFactory[T, D]) setData(data []byte) error {
+	var zero T
+	v.Data = zero
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, &v.Data)
+}
+
+// String is the same as [EncryptedJSONFactory.String].
+func (v EncryptedJSON[T]) String() string {
+	return EncryptedJSONFactory[T, dummy](v).String()
+}
+
+// GoString is the same as [EncryptedJSONFactory.GoString].
+func (v EncryptedJSON[T]) GoString() string {
+	return EncryptedJSONFactory[T, dummy](v).GoString()
+}
+
+// Format is the same as [EncryptedJSONFactory.Format].
+func (v EncryptedJSON[T]) Format(f fmt.State, verb rune) {
+	EncryptedJSONFactory[T, dummy](v).Format(f, verb)
+}
+
+// MarshalJSON is the same as [EncryptedJSONFactory.MarshalJSON].
+func (v EncryptedJSON[T]) MarshalJSON() ([]byte, error) {
+	return EncryptedJSONFactory[T, dummy](v).MarshalJSON()
+}
+
+// UnmarshalJSON is the same as [EncryptedJSONFactory.UnmarshalJSON].
+func (v *EncryptedJSON[T]) UnmarshalJSON(data []byte) error {
+	return (*EncryptedJSONFactory[T, dummy])(v).UnmarshalJSON(data)
+}
+
+// Value is the same as [EncryptedJSONFactory.Value].
+func (v EncryptedJSON[T]) Value() (driver.Value, error) {
+	return EncryptedJSONFactory[T, dummy](v).Value()
+}
+
+// Scan is the same as [EncryptedJSONFactory.Scan].
+func (v *EncryptedJSON[T]) Scan(value interface{}) error {
+	return (*EncryptedJSONFactory[T, dummy])(v).Scan(value)
+}
@@ -0,0 +1,70 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEncryptedJSON(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	BindCrypterTo[EncryptedValueFactory[dummy1]](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummy1]]() })
+
+	type profile struct {
+		CardNumber string
+		Expiry     string
+	}
+	type EncryptedProfile = EncryptedJSONFactory[profile, dummy1]
+
+	orig := EncryptedProfile{Data: profile{CardNumber: "4111111111111111", Expiry: "12/30"}}
+
+	t.Run("JSON", func(t *testing.T) {
+		type user struct {
+			Name    string
+			Profile EncryptedProfile
+		}
+
+		enc, err := json.Marshal(user{Name: "John", Profile: orig})
+		RequireNoError(t, err)
+
+		if bytes.Contains(enc, []byte("4111111111111111")) {
+			t.Fatalf("encrypted text contains plaintext")
+		}
+
+		var dec user
+		RequireNoError(t, json.Unmarshal(enc, &dec))
+		RequireEqual(t, dec.Profile, orig)
+
+		dec.Profile = orig
+		RequireNoError(t, json.Unmarshal([]byte(`{"Name":"John","Profile":""}`), &dec))
+		RequireEqual(t, dec.Profile, EncryptedProfile{})
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		enc, err := orig.Value()
+		RequireNoError(t, err)
+
+		dec := EncryptedProfile{Data: profile{Expiry: "stale"}}
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec, orig)
+
+		RequireNoError(t, dec.Scan(nil))
+		RequireEqual(t, dec, EncryptedProfile{})
+	})
+
+	t.Run("default crypter", func(t *testing.T) {
+		BindCrypterTo[EncryptedValue](&c1)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+		enc, err := EncryptedJSON[profile](orig).Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedJSON[profile]
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Data, orig.Data)
+	})
+}
@@ -0,0 +1,123 @@
+package silent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"reflect"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+var ErrRootKeyTooShort = errors.New("root key is too short, must be at least 32 bytes")
+
+// GenerateKey generates a new random 32-byte key suitable for [MultiKeyCrypter.AddKey]
+// and other crypters in this package. The key is read from crypto/rand.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GenerateKeyBase64 is like [GenerateKey], but returns the key encoded with standard base64,
+// ready to be stored in a config file or a secret manager.
+func GenerateKeyBase64() (string, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+type deriveKeyParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// DeriveKeyOption configures the cost parameters of [DeriveKey].
+type DeriveKeyOption func(p *deriveKeyParams)
+
+// WithArgon2Params overrides the Argon2id cost parameters used by [DeriveKey]:
+// the number of passes over the memory, the memory size in KiB and the degree of parallelism.
+// The defaults are 3 passes, 64 MiB and 4 threads, as recommended by RFC 9106.
+func WithArgon2Params(time, memory uint32, threads uint8) DeriveKeyOption {
+	if time < 1 || threads < 1 || memory < 8*uint32(threads) {
+		panic("misconfiguration: invalid argon2 parameters")
+	}
+
+	return func(p *deriveKeyParams) {
+		p.time = time
+		p.memory = memory
+		p.threads = threads
+	}
+}
+
+// DeriveKey derives a 32-byte key suitable for [MultiKeyCrypter.AddKey] from a passphrase or
+// a master secret using Argon2id. The result is deterministic: the same passphrase, salt and parameters
+// always produce the same key.
+//
+// The salt should be random, at least 16 bytes long, and must be stored alongside the configuration,
+// since the key can't be derived again without it. Likewise, changing the cost parameters changes the derived key,
+// which makes all data encrypted with the old key undecryptable.
+func DeriveKey(passphrase, salt []byte, opts ...DeriveKeyOption) []byte {
+	p := deriveKeyParams{time: 3, memory: 64 * 1024, threads: 4}
+	for _, opt := range opts {
+		opt(&p)
+	}
+
+	return argon2.IDKey(passphrase, salt, p.time, p.memory, p.threads, 32)
+}
+
+// DeriveKeyFor derives a 32-byte subkey specific to the EncryptedValue type F from the root key using HKDF.
+// This gives domain separation between types, e.g. different columns, while only one secret has to be managed.
+// The root key must be at least 32 bytes long.
+//
+// The type is identified by its package path and name, so renaming or moving the type changes the derived key,
+// which makes all data encrypted with the old key undecryptable.
+func DeriveKeyFor[F EncryptedValueFactory[T], T any](root []byte) ([]byte, error) {
+	if len(root) < 32 {
+		return nil, ErrRootKeyTooShort
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Name() == "" {
+		return nil, errors.New("unable to derive a key for an unnamed type")
+	}
+
+	info := make([]byte, 0, len("silent type ")+len(t.PkgPath())+1+len(t.Name()))
+	info = append(info, "silent type "...)
+	info = append(info, t.PkgPath()...)
+	info = append(info, '.')
+	info = append(info, t.Name()...)
+
+	res := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, root, nil, info), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BindDerivedCrypterTo binds a new [MultiKeyCrypter] to the EncryptedValue type F.
+// The crypter uses a single key with ID 1, derived from the root key with [DeriveKeyFor].
+// It panics if the key can't be derived, or the type already has a bound crypter.
+//
+//	silent.BindDerivedCrypterTo[EncryptedEmail](rootKey)
+//	silent.BindDerivedCrypterTo[EncryptedPhone](rootKey)
+//
+// To rotate keys, build the crypter manually, adding keys derived from the old and new root keys.
+func BindDerivedCrypterTo[F EncryptedValueFactory[T], T any](root []byte, opts ...BindOption) {
+	key, err := DeriveKeyFor[F, T](root)
+	if err != nil {
+		panic("misconfiguration: " + err.Error())
+	}
+
+	c := NewMultiKeyCrypter()
+	c.AddKey(1, key)
+	BindCrypterTo[F, T](c, opts...)
+}
@@ -0,0 +1,136 @@
+package silent
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	t.Run("raw", func(t *testing.T) {
+		k1, err := GenerateKey()
+		RequireNoError(t, err)
+		RequireEqual(t, len(k1), 32)
+
+		k2, err := GenerateKey()
+		RequireNoError(t, err)
+		RequireEqual(t, len(k2), 32)
+
+		RequireTrue(t, !bytes.Equal(k1, k2))
+
+		// usable as is
+		c := MultiKeyCrypter{}
+		RequireNoError(t, c.AddKeyErr(1, k1))
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		s1, err := GenerateKeyBase64()
+		RequireNoError(t, err)
+		RequireEqual(t, len(DecodeBase64(t, s1)), 32)
+
+		s2, err := GenerateKeyBase64()
+		RequireNoError(t, err)
+		RequireTrue(t, s1 != s2)
+	})
+}
+
+func TestDeriveKey(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	salt1 := []byte("0123456789abcdef")
+	salt2 := []byte("fedcba9876543210")
+
+	t.Run("deterministic", func(t *testing.T) {
+		k1 := DeriveKey(passphrase, salt1)
+		RequireEqual(t, len(k1), 32)
+
+		k2 := DeriveKey(passphrase, salt1)
+		RequireTrue(t, bytes.Equal(k1, k2))
+
+		// usable as is
+		c := MultiKeyCrypter{}
+		RequireNoError(t, c.AddKeyErr(1, k1))
+	})
+
+	t.Run("different salts", func(t *testing.T) {
+		k1 := DeriveKey(passphrase, salt1)
+		k2 := DeriveKey(passphrase, salt2)
+		RequireTrue(t, !bytes.Equal(k1, k2))
+	})
+
+	t.Run("different passphrases", func(t *testing.T) {
+		k1 := DeriveKey(passphrase, salt1)
+		k2 := DeriveKey([]byte("another passphrase"), salt1)
+		RequireTrue(t, !bytes.Equal(k1, k2))
+	})
+
+	t.Run("params", func(t *testing.T) {
+		k1 := DeriveKey(passphrase, salt1, WithArgon2Params(1, 1024, 1))
+		RequireEqual(t, len(k1), 32)
+		RequireTrue(t, bytes.Equal(k1, DeriveKey(passphrase, salt1, WithArgon2Params(1, 1024, 1))))
+		RequireTrue(t, !bytes.Equal(k1, DeriveKey(passphrase, salt1)))
+		RequireTrue(t, !bytes.Equal(k1, DeriveKey(passphrase, salt1, WithArgon2Params(2, 1024, 1))))
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		WithArgon2Params(0, 1024, 1)
+	})
+}
+
+type derivedDummy1 struct{}
+type derivedDummy2 struct{}
+
+func TestDerivedCrypter(t *testing.T) {
+	type EncryptedValue1 = EncryptedValueFactory[derivedDummy1]
+	type EncryptedValue2 = EncryptedValueFactory[derivedDummy2]
+
+	root := DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")
+
+	t.Run("derive", func(t *testing.T) {
+		k1, err := DeriveKeyFor[EncryptedValue1](root)
+		RequireNoError(t, err)
+		RequireEqual(t, len(k1), 32)
+
+		k1again, err := DeriveKeyFor[EncryptedValue1](root)
+		RequireNoError(t, err)
+		RequireTrue(t, bytes.Equal(k1, k1again))
+
+		k2, err := DeriveKeyFor[EncryptedValue2](root)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(k1, k2))
+
+		k1other, err := DeriveKeyFor[EncryptedValue1](DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(k1, k1other))
+	})
+
+	t.Run("short root", func(t *testing.T) {
+		_, err := DeriveKeyFor[EncryptedValue1](root[:31])
+		RequireErrorIs(t, err, ErrRootKeyTooShort)
+	})
+
+	t.Run("unnamed type", func(t *testing.T) {
+		_, err := DeriveKeyFor[EncryptedValueFactory[struct{}]](root)
+		RequireError(t, err)
+	})
+
+	t.Run("bind", func(t *testing.T) {
+		BindDerivedCrypterTo[EncryptedValue1](root)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+		BindDerivedCrypterTo[EncryptedValue2](root)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue2]() })
+
+		enc, err := EncryptedValue1("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		var v1 EncryptedValue1
+		RequireNoError(t, v1.Scan(enc))
+		RequireEqual(t, string(v1), "Hello, world!")
+
+		// values don't cross-decrypt
+		var v2 EncryptedValue2
+		RequireError(t, v2.Scan(enc))
+	})
+}
@@ -0,0 +1,58 @@
+package kmscrypt
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// AWSClient is the subset of the AWS KMS client used by [NewAWSKeyService]. It is satisfied by *kms.Client.
+type AWSClient interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+type awsKeyService struct {
+	client AWSClient
+	keyID  string
+}
+
+// NewAWSKeyService creates a [KeyService] backed by AWS KMS.
+// Data keys are generated under the KMS key identified by keyID, which can be a key ID, a key ARN, or an alias.
+func NewAWSKeyService(client AWSClient, keyID string) KeyService {
+	if client == nil {
+		panic("misconfiguration: client is nil")
+	}
+
+	if keyID == "" {
+		panic("misconfiguration: key id is empty")
+	}
+
+	return &awsKeyService{client: client, keyID: keyID}
+}
+
+func (s *awsKeyService) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := s.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(s.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+func (s *awsKeyService) UnwrapDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	// KeyId is deliberately not passed, so data keys generated before an alias was moved to a new key can still be unwrapped
+	out, err := s.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Plaintext, nil
+}
@@ -0,0 +1,262 @@
+// Package kmscrypt provides a [silent.Crypter] that implements envelope encryption on top of a key management service.
+// It lives in a separate module to keep cloud SDKs out of the core module dependencies.
+//
+// Every value is encrypted with a data key, which is generated by the key management service.
+// The data key is stored next to the encrypted value in the wrapped (encrypted) form, and the key management service
+// is asked to unwrap it on decryption. This way, the master key never leaves the key management service,
+// and plaintext data keys are only held in memory for a short time.
+//
+// AWS KMS is supported out of the box with [NewAWSKeyService]. Other services can be plugged in
+// by implementing the [KeyService] interface.
+package kmscrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/destel/silent"
+)
+
+// KeyService generates and unwraps data keys. Implementations must be safe for concurrent use.
+type KeyService interface {
+	// GenerateDataKey returns a new 32-byte data key in plaintext and wrapped forms.
+	GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, err error)
+
+	// UnwrapDataKey returns the plaintext form of a data key previously returned by GenerateDataKey.
+	UnwrapDataKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+const (
+	version    = 1
+	nonceSize  = 12
+	tagSize    = 16
+	headerSize = 5 // version + wrapped key length
+)
+
+var _ silent.ContextCrypter = (*Crypter)(nil)
+
+// Crypter encrypts data with data keys provided by a [KeyService].
+//
+// The encrypted data consists of a version byte, a little-endian length of the wrapped data key, the wrapped data key,
+// a random nonce and the data sealed with AES-256-GCM. Everything that precedes the nonce is authenticated as associated data.
+type Crypter struct {
+	svc KeyService
+
+	// CacheTTL, if positive, enables caching of data keys to reduce the number of calls to the key service.
+	// The same data key is used for encryption for up to CacheTTL, and unwrapped data keys are remembered for up to CacheTTL.
+	// Longer TTLs mean fewer calls, but keep plaintext data keys in memory for longer.
+	CacheTTL time.Duration
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu           sync.Mutex
+	encKey       *cachedKey
+	decKeys      map[string]*cachedKey
+	lastDecPrune time.Time
+}
+
+type cachedKey struct {
+	wrapped []byte
+	aead    cipher.AEAD
+	expires time.Time
+}
+
+// New creates a Crypter that uses the provided key service.
+func New(svc KeyService) *Crypter {
+	if svc == nil {
+		panic("misconfiguration: key service is nil")
+	}
+
+	return &Crypter{svc: svc}
+}
+
+// Encrypt is the same as [Crypter.EncryptContext] with the background context.
+func (c *Crypter) Encrypt(data []byte) ([]byte, error) {
+	return c.EncryptContext(context.Background(), data)
+}
+
+// Decrypt is the same as [Crypter.DecryptContext] with the background context.
+func (c *Crypter) Decrypt(data []byte) ([]byte, error) {
+	return c.DecryptContext(context.Background(), data)
+}
+
+// EncryptContext encrypts the data with a data key. The context is passed to the key service.
+func (c *Crypter) EncryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	key, err := c.encryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	size := headerSize + len(key.wrapped) + nonceSize + len(data) + tagSize
+	res := make([]byte, 0, size)
+	res = append(res, version)
+	res = binary.LittleEndian.AppendUint32(res, uint32(len(key.wrapped)))
+	res = append(res, key.wrapped...)
+	ad := res
+
+	res = res[:len(res)+nonceSize]
+	nonce := res[len(ad):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return key.aead.Seal(res, nonce, data, ad), nil
+}
+
+// DecryptContext decrypts the data, unwrapping the data key with the key service. The context is passed to the key service.
+func (c *Crypter) DecryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	v := data[0]
+	if v != version {
+		return nil, &silent.DecryptError{Kind: silent.KindUnsupportedVersion, Version: v, Cause: silent.ErrUnsupportedVersion}
+	}
+
+	if len(data) < headerSize {
+		return nil, &silent.DecryptError{Kind: silent.KindCorrupt, Version: v, Cause: silent.ErrTruncated}
+	}
+
+	wrappedSize := binary.LittleEndian.Uint32(data[1:headerSize])
+	if uint64(len(data)) < uint64(headerSize)+uint64(wrappedSize)+nonceSize+tagSize {
+		return nil, &silent.DecryptError{Kind: silent.KindCorrupt, Version: v, Cause: silent.ErrTruncated}
+	}
+
+	adSize := headerSize + int(wrappedSize)
+	ad := data[:adSize]
+	wrapped := data[headerSize:adSize]
+
+	key, err := c.decryptionKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := data[adSize : adSize+nonceSize]
+	res, err := key.aead.Open(nil, nonce, data[adSize+nonceSize:], ad)
+	if err != nil {
+		return nil, &silent.DecryptError{Kind: silent.KindAuthFailed, Version: v, Cause: err}
+	}
+
+	return res, nil
+}
+
+// encryptionKey returns the cached data key, or generates a new one.
+func (c *Crypter) encryptionKey(ctx context.Context) (*cachedKey, error) {
+	now := c.now()
+
+	if c.CacheTTL > 0 {
+		c.mu.Lock()
+		key := c.encKey
+		c.mu.Unlock()
+
+		if key != nil && now.Before(key.expires) {
+			return key, nil
+		}
+	}
+
+	plaintext, wrapped, err := c.svc.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newCachedKey(plaintext, wrapped, now.Add(c.CacheTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.CacheTTL > 0 {
+		c.mu.Lock()
+		c.encKey = key
+		c.mu.Unlock()
+	}
+
+	return key, nil
+}
+
+// decryptionKey returns the cached unwrapped data key, or unwraps it with the key service.
+func (c *Crypter) decryptionKey(ctx context.Context, wrapped []byte) (*cachedKey, error) {
+	now := c.now()
+
+	if c.CacheTTL > 0 {
+		c.mu.Lock()
+		key := c.decKeys[string(wrapped)]
+		c.mu.Unlock()
+
+		if key != nil && now.Before(key.expires) {
+			return key, nil
+		}
+	}
+
+	plaintext, err := c.svc.UnwrapDataKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newCachedKey(plaintext, bytes.Clone(wrapped), now.Add(c.CacheTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.CacheTTL > 0 {
+		c.mu.Lock()
+		if c.decKeys == nil {
+			c.decKeys = make(map[string]*cachedKey)
+		}
+
+		// drop expired keys from time to time, so the cache doesn't grow indefinitely
+		if now.Sub(c.lastDecPrune) >= c.CacheTTL {
+			for k, v := range c.decKeys {
+				if !now.Before(v.expires) {
+					delete(c.decKeys, k)
+				}
+			}
+			c.lastDecPrune = now
+		}
+
+		c.decKeys[string(key.wrapped)] = key
+		c.mu.Unlock()
+	}
+
+	return key, nil
+}
+
+func (c *Crypter) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+var errInvalidDataKey = errors.New("data key must be 32 bytes")
+
+func newCachedKey(plaintext, wrapped []byte, expires time.Time) (*cachedKey, error) {
+	if len(plaintext) != 32 {
+		return nil, errInvalidDataKey
+	}
+
+	block, err := aes.NewCipher(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedKey{wrapped: wrapped, aead: aead, expires: expires}, nil
+}
@@ -0,0 +1,225 @@
+package kmscrypt_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+
+	"github.com/destel/silent"
+	"github.com/destel/silent/kmscrypt"
+)
+
+// fakeKMS is an in-memory implementation of the AWS KMS API subset.
+// Data keys are wrapped with AES-GCM under a random master key.
+type fakeKMS struct {
+	master cipher.AEAD
+
+	generateCalls atomic.Int64
+	decryptCalls  atomic.Int64
+}
+
+func newFakeKMS(t *testing.T) *fakeKMS {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &fakeKMS{master: aead}
+}
+
+func (f *fakeKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	f.generateCalls.Add(1)
+
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, f.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &kms.GenerateDataKeyOutput{
+		Plaintext:      plaintext,
+		CiphertextBlob: f.master.Seal(nonce, nonce, plaintext, []byte(*params.KeyId)),
+		KeyId:          params.KeyId,
+	}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.decryptCalls.Add(1)
+
+	blob := params.CiphertextBlob
+	if len(blob) < f.master.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+
+	plaintext, err := f.master.Open(nil, blob[:f.master.NonceSize()], blob[f.master.NonceSize():], []byte("alias/test"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.DecryptOutput{Plaintext: plaintext}, nil
+}
+
+func TestCrypter(t *testing.T) {
+	text := []byte("Hello, World!")
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		fake := newFakeKMS(t)
+		c := kmscrypt.New(kmscrypt.NewAWSKeyService(fake, "alias/test"))
+
+		for _, text := range [][]byte{nil, []byte("Hello, World!"), bytes.Repeat([]byte("Lorem ipsum "), 1000)} {
+			enc, err := c.Encrypt(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(text) == 0 {
+				if len(enc) != 0 {
+					t.Fatalf("expected empty data, got %d bytes", len(enc))
+				}
+				continue
+			}
+
+			if bytes.Contains(enc, text) {
+				t.Fatalf("encrypted data contains plaintext")
+			}
+
+			dec, err := c.Decrypt(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(dec, text) {
+				t.Fatalf("expected %q, got %q", text, dec)
+			}
+		}
+
+		// no caching by default
+		if fake.generateCalls.Load() != 2 || fake.decryptCalls.Load() != 2 {
+			t.Fatalf("unexpected number of calls: %d generate, %d decrypt", fake.generateCalls.Load(), fake.decryptCalls.Load())
+		}
+	})
+
+	t.Run("other master key", func(t *testing.T) {
+		c1 := kmscrypt.New(kmscrypt.NewAWSKeyService(newFakeKMS(t), "alias/test"))
+		c2 := kmscrypt.New(kmscrypt.NewAWSKeyService(newFakeKMS(t), "alias/test"))
+
+		enc, err := c1.Encrypt(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c2.Decrypt(enc); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		c := kmscrypt.New(kmscrypt.NewAWSKeyService(newFakeKMS(t), "alias/test"))
+
+		enc, err := c.Encrypt(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tampered := bytes.Clone(enc)
+		tampered[len(tampered)-1] ^= 1
+
+		var decErr *silent.DecryptError
+		if _, err := c.Decrypt(tampered); !errors.As(err, &decErr) || decErr.Kind != silent.KindAuthFailed {
+			t.Fatalf("expected auth failure, got %v", err)
+		}
+
+		if _, err := c.Decrypt(enc[:20]); !errors.Is(err, silent.ErrTruncated) {
+			t.Fatalf("expected truncated error, got %v", err)
+		}
+
+		if _, err := c.Decrypt([]byte{7, 1, 2}); !errors.Is(err, silent.ErrUnsupportedVersion) {
+			t.Fatalf("expected unsupported version error, got %v", err)
+		}
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		fake := newFakeKMS(t)
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		c := kmscrypt.New(kmscrypt.NewAWSKeyService(fake, "alias/test"))
+		c.CacheTTL = time.Minute
+		c.Now = func() time.Time { return now }
+
+		var encs [][]byte
+		for i := 0; i < 5; i++ {
+			enc, err := c.Encrypt(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encs = append(encs, enc)
+		}
+
+		for _, enc := range encs {
+			if _, err := c.Decrypt(enc); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if fake.generateCalls.Load() != 1 || fake.decryptCalls.Load() != 1 {
+			t.Fatalf("unexpected number of calls: %d generate, %d decrypt", fake.generateCalls.Load(), fake.decryptCalls.Load())
+		}
+
+		// after the TTL, keys are requested again
+		now = now.Add(time.Minute)
+
+		if _, err := c.Encrypt(text); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Decrypt(encs[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if fake.generateCalls.Load() != 2 || fake.decryptCalls.Load() != 2 {
+			t.Fatalf("unexpected number of calls: %d generate, %d decrypt", fake.generateCalls.Load(), fake.decryptCalls.Load())
+		}
+	})
+
+	t.Run("value", func(t *testing.T) {
+		c := kmscrypt.New(kmscrypt.NewAWSKeyService(newFakeKMS(t), "alias/test"))
+
+		type dummy struct{}
+		type EncryptedValue = silent.EncryptedValueFactory[dummy]
+		silent.BindCrypterTo[EncryptedValue](c)
+		t.Cleanup(func() { silent.UnbindCrypter[EncryptedValue]() })
+
+		enc, err := EncryptedValue(text).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dec EncryptedValue
+		if err := dec.Scan(enc); err != nil {
+			t.Fatal(err)
+		}
+		if string(dec) != string(text) {
+			t.Fatalf("expected %q, got %q", text, dec)
+		}
+	})
+}
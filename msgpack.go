@@ -0,0 +1,104 @@
+package silent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// msgpack format codes, as defined in https://github.com/msgpack/msgpack/blob/master/spec.md
+const (
+	msgpackNil   byte = 0xc0
+	msgpackBin8  byte = 0xc4
+	msgpackBin16 byte = 0xc5
+	msgpackBin32 byte = 0xc6
+)
+
+// MarshalMsgpack implements the msgpack.Marshaler interface of github.com/vmihailenco/msgpack.
+// It encrypts the value and stores it as msgpack bin data. Empty values are stored as empty bin data.
+func (v EncryptedValueFactory[T]) MarshalMsgpack() ([]byte, error) {
+	if len(v) == 0 {
+		return appendMsgpackBin(nil, nil), nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	encData, err := crypter.Encrypt(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendMsgpackBin(make([]byte, 0, len(encData)+5), encData), nil
+}
+
+// UnmarshalMsgpack implements the msgpack.Unmarshaler interface of github.com/vmihailenco/msgpack.
+// It decrypts the value from msgpack bin data. Nil is decoded as an empty value.
+func (v *EncryptedValueFactory[T]) UnmarshalMsgpack(data []byte) error {
+	if len(data) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	var headerSize, size int
+	switch data[0] {
+	case msgpackNil:
+		*v = nil
+		return nil
+	case msgpackBin8:
+		headerSize = 2
+		if len(data) >= headerSize {
+			size = int(data[1])
+		}
+	case msgpackBin16:
+		headerSize = 3
+		if len(data) >= headerSize {
+			size = int(binary.BigEndian.Uint16(data[1:3]))
+		}
+	case msgpackBin32:
+		headerSize = 5
+		if len(data) >= headerSize {
+			size = int(binary.BigEndian.Uint32(data[1:5]))
+		}
+	default:
+		return fmt.Errorf("unable to unmarshal msgpack type 0x%02x into EncryptedValue", data[0])
+	}
+
+	if len(data) < headerSize || uint64(size) != uint64(len(data)-headerSize) {
+		return io.ErrUnexpectedEOF
+	}
+
+	if size == 0 {
+		*v = nil
+		return nil
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	res, err := crypter.Decrypt(data[headerSize:])
+	if err != nil {
+		return err
+	}
+
+	*v = res
+	return nil
+}
+
+func appendMsgpackBin(dst, data []byte) []byte {
+	switch {
+	case len(data) <= math.MaxUint8:
+		dst = append(dst, msgpackBin8, byte(len(data)))
+	case len(data) <= math.MaxUint16:
+		dst = append(dst, msgpackBin16)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(len(data)))
+	default:
+		dst = append(dst, msgpackBin32)
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(data)))
+	}
+	return append(dst, data...)
+}
@@ -0,0 +1,92 @@
+package silent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEncryptedValueMsgpack(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type user struct {
+		Username string          `msgpack:"username"`
+		Token    EncryptedValue1 `msgpack:"token"`
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, text := range texts {
+			orig := user{Username: "john", Token: EncryptedValue1(text)}
+
+			enc, err := msgpack.Marshal(orig)
+			RequireNoError(t, err)
+
+			if len(text) > 0 && bytes.Contains(enc, text) {
+				t.Fatalf("encoded data contains plaintext")
+			}
+
+			var dec user
+			RequireNoError(t, msgpack.Unmarshal(enc, &dec))
+			RequireEqual(t, dec.Username, orig.Username)
+			RequireEqual(t, string(dec.Token), string(orig.Token))
+		}
+	})
+
+	t.Run("bin sizes", func(t *testing.T) {
+		for _, size := range []int{1, 200, 300, 70000} {
+			orig := EncryptedValue1(bytes.Repeat([]byte("a"), size))
+
+			enc, err := msgpack.Marshal(orig)
+			RequireNoError(t, err)
+
+			var raw []byte
+			RequireNoError(t, msgpack.Unmarshal(enc, &raw))
+
+			dec, err := c1.Decrypt(raw)
+			RequireNoError(t, err)
+			RequireTrue(t, bytes.Equal(dec, orig))
+
+			var v EncryptedValue1
+			RequireNoError(t, msgpack.Unmarshal(enc, &v))
+			RequireTrue(t, bytes.Equal(v, orig))
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		enc, err := msgpack.Marshal(map[string]interface{}{"username": "john", "token": nil})
+		RequireNoError(t, err)
+
+		dec := user{Token: EncryptedValue1("stale")}
+		RequireNoError(t, msgpack.Unmarshal(enc, &dec))
+		RequireEqual(t, len(dec.Token), 0)
+
+		var v EncryptedValue1
+		RequireNoError(t, v.UnmarshalMsgpack([]byte{0xc0}))
+		RequireEqual(t, len(v), 0)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		enc, err := msgpack.Marshal(map[string]interface{}{"token": 42})
+		RequireNoError(t, err)
+
+		var dec user
+		RequireError(t, msgpack.Unmarshal(enc, &dec))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		enc, err := EncryptedValue1("Hello, World!").MarshalMsgpack()
+		RequireNoError(t, err)
+
+		var v EncryptedValue1
+		RequireError(t, v.UnmarshalMsgpack(enc[:len(enc)-1]))
+		RequireError(t, v.UnmarshalMsgpack(enc[:1]))
+		RequireError(t, v.UnmarshalMsgpack(nil))
+	})
+}
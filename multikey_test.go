@@ -2,8 +2,20 @@ package silent
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/minio/sio"
 )
 
 var texts = [][]byte{
@@ -54,7 +66,7 @@ func TestMultikey(t *testing.T) {
 	// same as c1, but with additional key
 	c2 := MultiKeyCrypter{}
 	c2.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
-	c2.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoeMRltzqPZM/Uv83oBgcEAX3M2sbgHIkiw+up8TtfFKmQ=="))
+	c2.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
 
 	// same as c1, but with encryption bypassed
 	c1bypass := MultiKeyCrypter{}
@@ -103,13 +115,1458 @@ func TestMultikey(t *testing.T) {
 		RequireEqual(t, string(encryptedText), "#Hello, World!")
 	})
 
+	t.Run("bypass prefix", func(t *testing.T) {
+		// plaintext that looks like bypassed data must not be misinterpreted
+		for _, c := range []*MultiKeyCrypter{&c1, &c1bypass} {
+			encryptedText, err := c.Encrypt([]byte("#foo"))
+			RequireNoError(t, err)
+
+			decryptedText, err := c.Decrypt(encryptedText)
+			RequireNoError(t, err)
+			RequireEqual(t, string(decryptedText), "#foo")
+		}
+
+		custom := MultiKeyCrypter{BypassPrefix: '~', Bypass: true}
+		custom.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		encryptedText, err := custom.Encrypt([]byte("#foo"))
+		RequireNoError(t, err)
+		RequireEqual(t, string(encryptedText), "~#foo")
+
+		decryptedText, err := custom.Decrypt(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "#foo")
+
+		_, err = custom.KeyIDOf(encryptedText)
+		RequireErrorIs(t, err, ErrBypassed)
+
+		// '#' is no longer special
+		_, err = custom.Decrypt([]byte("#foo"))
+		RequireErrorIs(t, err, ErrUnsupportedVersion)
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		collides := MultiKeyCrypter{BypassPrefix: 1, Bypass: true}
+		collides.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		_, _ = collides.Encrypt([]byte("foo"))
+	})
+
+	t.Run("add key errors", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		RequireErrorIs(t, c.AddKeyErr(0x1, []byte("short key")), ErrKeyTooShort)
+		RequireErrorIs(t, c.AddKeyErr(0x1, make([]byte, 33)), ErrKeyTooLong)
+		RequireErrorIs(t, c.AddKeyErr(0x1, make([]byte, 64)), ErrKeyTooLong)
+
+		RequireNoError(t, c.AddKeyErr(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")))
+		RequireErrorIs(t, c.AddKeyErr(0x1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU=")), ErrDuplicateKeyID)
+		RequireErrorIs(t, c.AddKeyErr(0x2, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")), ErrDuplicateKey)
+
+		// failed calls must not affect the crypter
+		runCrypterSubtests(t, "c should decrypt c1", &c, &c1)
+		runCrypterSubtests(t, "c1 should decrypt c", &c1, &c)
+	})
+
+	t.Run("encrypted size too large", func(t *testing.T) {
+		if math.MaxInt == math.MaxInt32 {
+			t.Skip("can't exceed sio limits on 32-bit platforms")
+		}
+
+		// larger than the maximum supported by sio
+		size, err := c1.EncryptedSize(math.MaxInt)
+		RequireError(t, err)
+		RequireEqual(t, size, 0)
+	})
+
+	t.Run("key caps", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddKeyWithCaps(0x2, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="), KeyCapDecrypt)
+
+		// decrypt-only key must not be selected for encryption, even though it was added last
+		encryptedText, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		keyID, err := readUint32(bytes.NewReader(encryptedText[1:]))
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x1))
+
+		// encrypt-only key must not be used for decryption
+		writer := MultiKeyCrypter{}
+		writer.AddKeyWithCaps(0x2, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="), KeyCapEncrypt)
+
+		encryptedText, err = writer.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = writer.Decrypt(encryptedText)
+		RequireErrorIs(t, err, ErrKeyNotAllowed)
+
+		// but the same key with decrypt capability can decrypt it
+		decryptedText, err := c.Decrypt(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+	})
+
+	t.Run("set encryption key", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+		c.AddKeyWithCaps(0x3, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="), KeyCapDecrypt)
+
+		RequireErrorIs(t, c.SetEncryptionKey(0x4), ErrUnknownKey)
+		RequireErrorIs(t, c.SetEncryptionKey(0x3), ErrKeyNotAllowed)
+		RequireNoError(t, c.SetEncryptionKey(0x1))
+
+		// keys added later must not take over
+		c.AddKey(0x4, make([]byte, 32))
+
+		encryptedText, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		keyID, err := c.KeyIDOf(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x1))
+
+		RequireErrorIs(t, c.RemoveKey(0x1), ErrActiveKey)
+		RequireNoError(t, c.RemoveKey(0x2))
+	})
+
+	t.Run("key ids", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		RequireEqual(t, c.KeyIDs(), []uint32{})
+
+		_, err := c.ActiveKeyID()
+		RequireErrorIs(t, err, ErrNoEncryptionKey)
+
+		c.AddKeyWithCaps(0x0, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="), KeyCapDecrypt)
+		_, err = c.ActiveKeyID()
+		RequireErrorIs(t, err, ErrNoEncryptionKey)
+
+		c.AddKey(0x7, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddKey(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+		RequireEqual(t, c.KeyIDs(), []uint32{0x0, 0x2, 0x7})
+
+		keyID, err := c.ActiveKeyID()
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x2))
+
+		RequireNoError(t, c.SetEncryptionKey(0x7))
+		keyID, err = c.ActiveKeyID()
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x7))
+	})
+
+	t.Run("named keys", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddNamedKey("2024-q1", DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+		RequireErrorIs(t, c.AddNamedKeyErr("", make([]byte, 32)), ErrInvalidKeyName)
+		RequireErrorIs(t, c.AddNamedKeyErr(strings.Repeat("a", 256), make([]byte, 32)), ErrInvalidKeyName)
+		RequireErrorIs(t, c.AddNamedKeyErr("2024-q1", make([]byte, 32)), ErrDuplicateKeyID)
+		RequireErrorIs(t, c.AddNamedKeyErr("2024-q2", DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")), ErrDuplicateKey)
+		RequireErrorIs(t, c.AddKeyErr(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc=")), ErrDuplicateKey)
+
+		// numeric-key data and named-key data coexist
+		runCrypterSubtests(t, "c should decrypt self", &c, &c)
+		runCrypterSubtests(t, "c should decrypt c1", &c, &c1)
+		runCrypterSubtests(t, "c1 should not decrypt c", &c1, &c)
+
+		encryptedText, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		RequireEqual(t, encryptedText[0], byte(3))
+
+		name, err := c.KeyNameOf(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, name, "2024-q1")
+
+		_, err = c.KeyIDOf(encryptedText)
+		RequireErrorIs(t, err, ErrNamedKey)
+
+		_, err = c.ActiveKeyID()
+		RequireErrorIs(t, err, ErrNamedKey)
+
+		// switch back to the numeric key
+		RequireErrorIs(t, c.SetNamedEncryptionKey("2024-q2"), ErrUnknownKey)
+		RequireNoError(t, c.SetEncryptionKey(0x1))
+
+		rekeyed, changed, err := c.ReKey(encryptedText)
+		RequireNoError(t, err)
+		RequireTrue(t, changed)
+
+		_, err = c.KeyNameOf(rekeyed)
+		RequireErrorIs(t, err, ErrNotNamedKey)
+
+		RequireNoError(t, c.SetNamedEncryptionKey("2024-q1"))
+		_, changed, err = c.ReKey(encryptedText)
+		RequireNoError(t, err)
+		RequireTrue(t, !changed)
+
+		// the name is reported in errors
+		_, err = c1.Decrypt(encryptedText)
+		var decErr *DecryptError
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindUnknownKey)
+		RequireEqual(t, decErr.KeyName, "2024-q1")
+
+		// aad
+		encryptedText, err = c.EncryptWithAAD([]byte("Hello, World!"), []byte("row 1"))
+		RequireNoError(t, err)
+		RequireEqual(t, encryptedText[0], byte(4))
+
+		decryptedText, err := c.DecryptWithAAD(encryptedText, []byte("row 1"))
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+
+		_, err = c.DecryptWithAAD(encryptedText, []byte("row 2"))
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+		// truncated header
+		for i := 1; i < 10; i++ {
+			_, err = c.Decrypt(encryptedText[:i])
+			RequireErrorIs(t, err, ErrTruncated)
+		}
+	})
+
+	t.Run("regression vector", func(t *testing.T) {
+		vector, err := MakeRegressionVector(&c2, texts[1])
+		RequireNoError(t, err)
+
+		text, err := c2.Decrypt(DecodeBase64(t, vector))
+		RequireNoError(t, err)
+		RequireEqual(t, text, texts[1])
+	})
+
+	t.Run("key id of", func(t *testing.T) {
+		encryptedText, err := c2.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		keyID, err := c1.KeyIDOf(encryptedText) // doesn't need to know the key
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x2))
+
+		bypassedText, err := c1bypass.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, err = c1.KeyIDOf(bypassedText)
+		RequireErrorIs(t, err, ErrBypassed)
+
+		_, err = c1.KeyIDOf(nil)
+		RequireErrorIs(t, err, ErrEmptyData)
+
+		_, err = c1.KeyIDOf(encryptedText[:3])
+		var decErr *DecryptError
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindCorrupt)
+
+		_, err = c1.KeyIDOf([]byte{7, 1, 0, 0, 0})
+		RequireErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("format of", func(t *testing.T) {
+		named := MultiKeyCrypter{}
+		named.AddNamedKey("2024-q1", DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+		encrypt := func(c *MultiKeyCrypter, aad string) []byte {
+			res, err := c.EncryptWithAAD([]byte("Hello, World!"), []byte(aad))
+			RequireNoError(t, err)
+			return res
+		}
+
+		v1 := encrypt(&c2, "")
+
+		for _, tc := range []struct {
+			data     []byte
+			expected Format
+		}{
+			{nil, FormatEmpty},
+			{[]byte{}, FormatEmpty},
+			{encrypt(&c1bypass, ""), FormatBypass},
+			{v1, FormatV1},
+			{encrypt(&c1, "aad"), FormatV2},
+			{encrypt(&named, ""), FormatV3},
+			{encrypt(&named, "aad"), FormatV4},
+			{v1[:len(v1)-12], FormatV1}, // the shortest possible body
+			{v1[:len(v1)-13], FormatUnknown},
+			{v1[:3], FormatUnknown},
+			{[]byte("Hello, World!"), FormatUnknown},
+			{append([]byte{7}, v1[1:]...), FormatUnknown},
+			{[]byte{0}, FormatUnknown},
+		} {
+			RequireEqual(t, c1.FormatOf(tc.data), tc.expected)
+		}
+	})
+
+	t.Run("magic", func(t *testing.T) {
+		cm := MultiKeyCrypter{Magic: true}
+		cm.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		named := MultiKeyCrypter{Magic: true}
+		named.AddNamedKey("2024-q1", DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+		runCrypterSubtests(t, "cm should decrypt self", &cm, &cm)
+		runCrypterSubtests(t, "cm should decrypt c1", &cm, &c1)
+		runCrypterSubtests(t, "c1 should decrypt cm", &c1, &cm)
+		runCrypterSubtests(t, "named should decrypt self", &named, &named)
+		runCrypterSubtests(t, "cm should not decrypt c1broken", &cm, &c1broken)
+
+		// legacy is a crypter with the same keys, but without the magic prefix
+		legacy := MultiKeyCrypter{}
+		legacy.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		legacy.AddNamedKey("2024-q1", DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+		for _, tc := range []struct {
+			c      *MultiKeyCrypter
+			aad    string
+			prefix string
+			format Format
+		}{
+			{&cm, "", "SLNT\x01", FormatV1},
+			{&cm, "aad", "SLNT\x02", FormatV2},
+			{&named, "", "SLNT\x03", FormatV3},
+			{&named, "aad", "SLNT\x04", FormatV4},
+		} {
+			encryptedText, err := tc.c.EncryptWithAAD(texts[2], []byte(tc.aad))
+			RequireNoError(t, err)
+			RequireTrue(t, bytes.HasPrefix(encryptedText, []byte(tc.prefix)))
+			RequireEqual(t, c1.FormatOf(encryptedText), tc.format)
+
+			size, err := tc.c.EncryptedSize(len(texts[2]))
+			RequireNoError(t, err)
+			RequireEqual(t, len(encryptedText), size)
+
+			text, err := legacy.DecryptWithAAD(encryptedText, []byte(tc.aad))
+			RequireNoError(t, err)
+			RequireEqual(t, text, texts[2])
+
+			// the prefix doesn't affect decryption
+			text, err = legacy.DecryptWithAAD(encryptedText[len("SLNT"):], []byte(tc.aad))
+			RequireNoError(t, err)
+			RequireEqual(t, text, texts[2])
+		}
+
+		encryptedText, err := cm.Encrypt(texts[1])
+		RequireNoError(t, err)
+
+		keyID, err := c1.KeyIDOf(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x1))
+
+		r, err := c1.DecryptReader(bytes.NewReader(encryptedText))
+		RequireNoError(t, err)
+		text, err := io.ReadAll(r)
+		RequireNoError(t, err)
+		RequireEqual(t, text, texts[1])
+
+		newData, reencrypted, err := cm.ReKey(encryptedText)
+		RequireNoError(t, err)
+		RequireTrue(t, !reencrypted)
+		RequireEqual(t, newData, encryptedText)
+
+		// bypassed data has no prefix
+		cmBypass := MultiKeyCrypter{Magic: true, Bypass: true}
+		cmBypass.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		bypassedText, err := cmBypass.Encrypt(texts[1])
+		RequireNoError(t, err)
+		RequireEqual(t, string(bypassedText), "#"+string(texts[1]))
+	})
+
+	t.Run("magic errors", func(t *testing.T) {
+		encryptedText, err := c1.Encrypt(texts[1])
+		RequireNoError(t, err)
+
+		var decErr *DecryptError
+		for _, tc := range []struct {
+			data []byte
+			kind DecryptErrorKind
+		}{
+			{[]byte("S"), KindCorrupt},
+			{[]byte("SLN"), KindCorrupt},
+			{[]byte("SLNT"), KindCorrupt},
+			{[]byte("SLNT\x07"), KindUnsupportedVersion},
+			{[]byte("SLOT\x01"), KindUnsupportedVersion},
+			{[]byte("Some text"), KindUnsupportedVersion},
+			{append([]byte("SLNT"), encryptedText[:10]...), KindCorrupt},
+		} {
+			_, err := c1.Decrypt(tc.data)
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, tc.kind)
+		}
+
+		_, err = c1.KeyIDOf([]byte("SLNT"))
+		RequireErrorIs(t, err, ErrTruncated)
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		c := MultiKeyCrypter{BypassPrefix: 'S'}
+		c.IsBypassed([]byte("SLNT"))
+	})
+
+	t.Run("decrypt reader with key id", func(t *testing.T) {
+		encryptedText, err := c2.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		r, keyID, err := c2.DecryptReaderWithKeyID(bytes.NewReader(encryptedText))
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x2))
+
+		decryptedText, err := io.ReadAll(r)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+
+		// the reader is usable even if the key ID is not available
+		bypassedText, err := c1bypass.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		r, _, err = c1.DecryptReaderWithKeyID(bytes.NewReader(bypassedText))
+		RequireErrorIs(t, err, ErrBypassed)
+
+		decryptedText, err = io.ReadAll(r)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+
+		r, _, err = c1.DecryptReaderWithKeyID(bytes.NewReader(nil))
+		RequireErrorIs(t, err, ErrEmptyData)
+
+		decryptedText, err = io.ReadAll(r)
+		RequireNoError(t, err)
+		RequireEqual(t, len(decryptedText), 0)
+
+		named := MultiKeyCrypter{}
+		named.AddNamedKey("2024-q1", DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+		encryptedText, err = named.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		r, _, err = named.DecryptReaderWithKeyID(bytes.NewReader(encryptedText))
+		RequireErrorIs(t, err, ErrNamedKey)
+
+		decryptedText, err = io.ReadAll(r)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+
+		// real errors come without a reader
+		r, _, err = c1.DecryptReaderWithKeyID(bytes.NewReader(encryptedText))
+		RequireErrorIs(t, err, ErrUnknownKey)
+		RequireTrue(t, r == nil)
+	})
+
+	t.Run("rekey", func(t *testing.T) {
+		oldText, err := c1.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		newText, changed, err := c2.ReKey(oldText)
+		RequireNoError(t, err)
+		RequireTrue(t, changed)
+
+		keyID, err := c2.KeyIDOf(newText)
+		RequireNoError(t, err)
+		RequireEqual(t, keyID, uint32(0x2))
+
+		decryptedText, err := c2.Decrypt(newText)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+
+		// already under the newest key
+		sameText, changed, err := c2.ReKey(newText)
+		RequireNoError(t, err)
+		RequireTrue(t, !changed)
+		RequireTrue(t, bytes.Equal(sameText, newText))
+
+		// bypass data gets encrypted
+		bypassedText, err := c1bypass.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		newText, changed, err = c2.ReKey(bypassedText)
+		RequireNoError(t, err)
+		RequireTrue(t, changed)
+		RequireTrue(t, !bytes.Contains(newText, []byte("Hello, World!")))
+
+		// empty data stays empty
+		newText, changed, err = c2.ReKey(nil)
+		RequireNoError(t, err)
+		RequireTrue(t, !changed)
+		RequireEqual(t, len(newText), 0)
+
+		// data under unknown keys can't be rotated
+		_, _, err = c1.ReKey(sameText)
+		RequireErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("remove key", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddKey(0x2, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+		c.AddKeyWithCaps(0x3, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="), KeyCapDecrypt)
+
+		encryptedText1, err := c1.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		encryptedText2, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		RequireErrorIs(t, c.RemoveKey(0x2), ErrActiveKey)
+		RequireErrorIs(t, c.RemoveKey(0x4), ErrUnknownKey)
+
+		RequireNoError(t, c.RemoveKey(0x1))
+		RequireErrorIs(t, c.RemoveKey(0x1), ErrUnknownKey)
+
+		_, err = c.Decrypt(encryptedText1)
+		RequireErrorIs(t, err, ErrUnknownKey)
+
+		decryptedText, err := c.Decrypt(encryptedText2)
+		RequireNoError(t, err)
+		RequireEqual(t, string(decryptedText), "Hello, World!")
+
+		// a crypter with decrypt-only keys has no active key, so all of them can be removed
+		c = MultiKeyCrypter{}
+		c.AddKeyWithCaps(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="), KeyCapDecrypt)
+
+		RequireNoError(t, c.RemoveKey(0x1))
+
+		_, err = c.Decrypt(encryptedText1)
+		RequireErrorIs(t, err, ErrUnknownKey)
+
+		// keys can be added again
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		runCrypterSubtests(t, "c should decrypt c1 after re-adding the key", &c, &c1)
+	})
+
+	t.Run("key selector", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddKey(0x2, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+		c.KeySelector = func(data []byte) (uint32, error) {
+			if bytes.HasPrefix(data, []byte("tenant1:")) {
+				return 0x1, nil
+			}
+			if bytes.HasPrefix(data, []byte("tenant3:")) {
+				return 0x3, nil
+			}
+			return 0x2, nil
+		}
+
+		for _, tc := range []struct {
+			text  string
+			keyID uint32
+		}{
+			{"tenant1:Hello, World!", 0x1},
+			{"tenant2:Hello, World!", 0x2},
+		} {
+			encryptedText, err := c.Encrypt([]byte(tc.text))
+			RequireNoError(t, err)
+
+			keyID, err := readUint32(bytes.NewReader(encryptedText[1:]))
+			RequireNoError(t, err)
+			RequireEqual(t, keyID, tc.keyID)
+
+			decryptedText, err := c.Decrypt(encryptedText)
+			RequireNoError(t, err)
+			RequireEqual(t, string(decryptedText), tc.text)
+		}
+
+		_, err := c.Encrypt([]byte("tenant3:Hello, World!"))
+		RequireErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("decrypt batch", func(t *testing.T) {
+		var batch [][]byte
+		for _, text := range texts {
+			encryptedText, err := c1.Encrypt(text)
+			RequireNoError(t, err)
+			batch = append(batch, encryptedText)
+		}
+
+		// corrupt the second value
+		batch[1] = bytes.Clone(batch[1])
+		batch[1][len(batch[1])-1] ^= 1
+
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		t.Run("fail fast", func(t *testing.T) {
+			c.BatchMode = BatchFailFast
+
+			res, err := c.DecryptBatch(batch)
+			RequireError(t, err)
+			RequireTrue(t, res == nil)
+		})
+
+		t.Run("collect errors", func(t *testing.T) {
+			c.BatchMode = BatchCollectErrors
+
+			res, err := c.DecryptBatch(batch)
+			RequireError(t, err)
+
+			var batchErr *BatchError
+			RequireTrue(t, errors.As(err, &batchErr))
+			RequireEqual(t, len(batchErr.Errors), len(batch))
+			RequireEqual(t, len(res), len(batch))
+
+			for i, text := range texts {
+				if i == 1 {
+					RequireError(t, batchErr.Errors[i])
+					RequireTrue(t, res[i] == nil)
+					continue
+				}
+
+				RequireNoError(t, batchErr.Errors[i])
+				RequireEqual(t, res[i], text)
+			}
+
+			var decErr *DecryptError
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, KindAuthFailed)
+		})
+
+		t.Run("no errors", func(t *testing.T) {
+			c.BatchMode = BatchCollectErrors
+
+			res, err := c.DecryptBatch([][]byte{batch[0], batch[2]})
+			RequireNoError(t, err)
+			RequireEqual(t, res[1], texts[2])
+		})
+	})
+
+	t.Run("encrypt batch", func(t *testing.T) {
+		batch := [][]byte{texts[1], nil, texts[2], bytes.Repeat([]byte("a"), 200000), texts[0], []byte("b")}
+
+		for _, c := range []*MultiKeyCrypter{&c1, &c1bypass} {
+			res, err := c.EncryptBatch(batch)
+			RequireNoError(t, err)
+			RequireEqual(t, len(res), len(batch))
+
+			for i, v := range batch {
+				if len(v) == 0 {
+					RequireTrue(t, res[i] == nil)
+					continue
+				}
+
+				size, err := c.EncryptedSize(len(v))
+				RequireNoError(t, err)
+				RequireEqual(t, len(res[i]), size)
+
+				dec, err := c1.Decrypt(res[i])
+				RequireNoError(t, err)
+				RequireEqual(t, dec, v)
+			}
+
+			// results don't overlap
+			_ = append(res[0], 0)
+			dec, err := c1.DecryptBatch(res)
+			RequireNoError(t, err)
+			for i, v := range batch {
+				RequireEqual(t, dec[i], v)
+				if len(v) == 0 {
+					RequireTrue(t, dec[i] == nil)
+				}
+			}
+
+			// same for decryption
+			_ = append(dec[0], 0)
+			RequireEqual(t, dec[2], batch[2])
+		}
+
+		t.Run("errors", func(t *testing.T) {
+			c := MultiKeyCrypter{}
+			c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+			c.KeySelector = func(data []byte) (uint32, error) {
+				if bytes.HasPrefix(data, []byte("bad")) {
+					return 0, ErrUnknownKey
+				}
+				return 0x1, nil
+			}
+
+			batch := [][]byte{[]byte("good"), []byte("bad"), []byte("good again")}
+
+			c.BatchMode = BatchFailFast
+			res, err := c.EncryptBatch(batch)
+			RequireErrorIs(t, err, ErrUnknownKey)
+			RequireTrue(t, res == nil)
+
+			c.BatchMode = BatchCollectErrors
+			res, err = c.EncryptBatch(batch)
+			var batchErr *BatchError
+			RequireTrue(t, errors.As(err, &batchErr))
+			RequireNoError(t, batchErr.Errors[0])
+			RequireErrorIs(t, batchErr.Errors[1], ErrUnknownKey)
+			RequireNoError(t, batchErr.Errors[2])
+			RequireTrue(t, res[1] == nil)
+
+			dec, err := c.DecryptBatch([][]byte{res[0], res[2]})
+			RequireNoError(t, err)
+			RequireEqual(t, string(dec[0]), "good")
+			RequireEqual(t, string(dec[1]), "good again")
+
+			c.DecryptOnly = true
+			_, err = c.EncryptBatch(batch)
+			RequireErrorIs(t, err, ErrEncryptDisabled)
+		})
+	})
+
+	t.Run("decrypt to writer", func(t *testing.T) {
+		text := make([]byte, 1<<20)
+		_, err := rand.Read(text)
+		RequireNoError(t, err)
+
+		encryptedText, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+
+		var buf bytes.Buffer
+		w := &throttledWriter{w: &buf, maxWrites: -1}
+		n, err := c1.DecryptTo(w, encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, n, int64(len(text)))
+		RequireTrue(t, bytes.Equal(buf.Bytes(), text))
+		RequireTrue(t, w.writes > 1) // must not be written in one go
+
+		// write errors must be surfaced
+		w = &throttledWriter{w: io.Discard, maxWrites: 2}
+		_, err = c1.DecryptReaderTo(w, bytes.NewReader(encryptedText))
+		RequireEqual(t, err, errTooManyWrites)
+	})
+
+	t.Run("decrypt errors", func(t *testing.T) {
+		encryptedText, err := c2.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		tampered := bytes.Clone(encryptedText)
+		tampered[len(tampered)-1] ^= 1
+
+		unsupported := bytes.Clone(encryptedText)
+		unsupported[0] = 7
+
+		encryptOnly := MultiKeyCrypter{}
+		encryptOnly.AddKeyWithCaps(0x2, DecodeBase64(t, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="), KeyCapEncrypt)
+
+		cases := []struct {
+			name      string
+			crypter   *MultiKeyCrypter
+			data      []byte
+			kind      DecryptErrorKind
+			keyID     uint32
+			sentinel  error
+			wantKeyID bool
+		}{
+			{"unknown key", &c1, encryptedText, KindUnknownKey, 0x2, ErrUnknownKey, true},
+			{"key not allowed", &encryptOnly, encryptedText, KindKeyNotAllowed, 0x2, ErrKeyNotAllowed, true},
+			{"auth failed", &c2, tampered, KindAuthFailed, 0x2, ErrAuthentication, true},
+			{"unsupported version", &c2, unsupported, KindUnsupportedVersion, 0, ErrUnsupportedVersion, false},
+			{"truncated header", &c2, encryptedText[:3], KindCorrupt, 0, ErrCorrupted, false},
+			{"truncated body", &c2, encryptedText[:len(encryptedText)-5], KindCorrupt, 0x2, ErrCorrupted, true},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := tc.crypter.Decrypt(tc.data)
+				RequireError(t, err)
+
+				var decErr *DecryptError
+				RequireTrue(t, errors.As(err, &decErr))
+				RequireEqual(t, decErr.Kind, tc.kind)
+				RequireEqual(t, decErr.Version, tc.data[0])
+				RequireTrue(t, decErr.Cause != nil)
+				if tc.wantKeyID {
+					RequireEqual(t, decErr.KeyID, tc.keyID)
+				}
+				if tc.sentinel != nil {
+					RequireErrorIs(t, err, tc.sentinel)
+				}
+			})
+		}
+	})
+
+	t.Run("decrypt only", func(t *testing.T) {
+		readOnly := MultiKeyCrypter{DecryptOnly: true}
+		readOnly.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		runCrypterSubtests(t, "readOnly should decrypt c1", &readOnly, &c1)
+
+		_, err := readOnly.Encrypt([]byte("Hello, World!"))
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		_, err = readOnly.EncryptWithAAD([]byte("Hello, World!"), []byte("aad"))
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		_, err = readOnly.EncryptWriter(io.Discard)
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		err = readOnly.EncryptStream(io.Discard, strings.NewReader("Hello, World!"))
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		// bypass mode doesn't help either
+		readOnly.Bypass = true
+		_, err = readOnly.Encrypt([]byte("Hello, World!"))
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+		readOnly.Bypass = false
+
+		// data from c1 is readable, but can't be rotated
+		readOnly.AddKey(0x2, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+		encryptedText, err := c1.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		_, _, err = readOnly.ReKey(encryptedText)
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		// value types
+		type dummy struct{}
+		type EncryptedValue = EncryptedValueFactory[dummy]
+		BindCrypterTo[EncryptedValue](&readOnly)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+		_, err = EncryptedValue("Hello, World!").Value()
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		_, err = json.Marshal(EncryptedValue("Hello, World!"))
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		var v EncryptedValue
+		RequireNoError(t, v.Scan(encryptedText))
+		RequireEqual(t, string(v), "Hello, World!")
+
+		jsonText, err := json.Marshal(base64.StdEncoding.EncodeToString(encryptedText))
+		RequireNoError(t, err)
+		RequireNoError(t, json.Unmarshal(jsonText, &v))
+		RequireEqual(t, string(v), "Hello, World!")
+	})
+
+	t.Run("reject bypass on decrypt", func(t *testing.T) {
+		bypassed, err := c1bypass.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		// permissive by default
+		dec, err := c1.Decrypt(bypassed)
+		RequireNoError(t, err)
+		RequireEqual(t, string(dec), "Hello, World!")
+
+		strict := MultiKeyCrypter{RejectBypassOnDecrypt: true}
+		strict.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		_, err = strict.Decrypt(bypassed)
+		RequireErrorIs(t, err, ErrBypassNotAllowed)
+
+		_, err = strict.DecryptReader(bytes.NewReader(bypassed))
+		RequireErrorIs(t, err, ErrBypassNotAllowed)
+
+		// encrypted data is not affected
+		runCrypterSubtests(t, "strict should decrypt c1", &strict, &c1)
+	})
+
+	t.Run("authentication vs corruption", func(t *testing.T) {
+		encryptedText, err := c1.Encrypt(texts[2])
+		RequireNoError(t, err)
+
+		tampered := bytes.Clone(encryptedText)
+		tampered[len(tampered)/2] ^= 1
+
+		truncated := encryptedText[:len(encryptedText)-20]
+
+		for _, tc := range []struct {
+			name     string
+			crypter  *MultiKeyCrypter
+			data     []byte
+			sentinel error
+			other    error
+		}{
+			{"tampered", &c1, tampered, ErrAuthentication, ErrCorrupted},
+			{"wrong key", &c1broken, encryptedText, ErrAuthentication, ErrCorrupted},
+			{"truncated", &c1, truncated, ErrCorrupted, ErrAuthentication},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := tc.crypter.Decrypt(tc.data)
+				RequireErrorIs(t, err, tc.sentinel)
+				RequireTrue(t, !errors.Is(err, tc.other))
+
+				// the sio error is still available
+				var sioErr sio.Error
+				RequireTrue(t, errors.As(err, &sioErr))
+
+				r, err := tc.crypter.DecryptReader(bytes.NewReader(tc.data))
+				if err == nil {
+					_, err = io.ReadAll(r)
+				}
+				RequireErrorIs(t, err, tc.sentinel)
+				RequireTrue(t, !errors.Is(err, tc.other))
+			})
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for _, size := range []int{13, 70000} {
+			encryptedText, err := c1.Encrypt(make([]byte, size))
+			RequireNoError(t, err)
+
+			// every length near the start, a sample of lengths after that, and the end of the first package
+			for i := 1; i < len(encryptedText); i++ {
+				if i > 100 && i%997 != 0 && i != len(encryptedText)-1 && i != 5+64*1024+32 {
+					continue
+				}
+
+				_, err := c1.Decrypt(encryptedText[:i])
+				RequireErrorIs(t, err, ErrTruncated)
+
+				var decErr *DecryptError
+				RequireTrue(t, errors.As(err, &decErr))
+				RequireEqual(t, decErr.Kind, KindCorrupt)
+
+				_, err = c1.DecryptReaderTo(io.Discard, bytes.NewReader(encryptedText[:i]))
+				RequireErrorIs(t, err, ErrTruncated)
+			}
+		}
+	})
+
+	t.Run("tampered header", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		c.AddKey(0x2, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+		RequireNoError(t, c.SetEncryptionKey(0x1))
+
+		encryptedText, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		cases := []struct {
+			name   string
+			tamper func(data []byte)
+			kind   DecryptErrorKind
+		}{
+			{"known key id", func(data []byte) { data[1] = 0x2 }, KindAuthFailed},
+			{"unknown key id", func(data []byte) { data[4] ^= 0x80 }, KindUnknownKey},
+			{"aad version", func(data []byte) { data[0] = 2 }, KindAuthFailed},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				tampered := bytes.Clone(encryptedText)
+				tc.tamper(tampered)
+
+				_, err := c.Decrypt(tampered)
+
+				var decErr *DecryptError
+				RequireTrue(t, errors.As(err, &decErr))
+				RequireEqual(t, decErr.Kind, tc.kind)
+
+				_, err = c.DecryptReaderTo(io.Discard, bytes.NewReader(tampered))
+				RequireTrue(t, errors.As(err, &decErr))
+				RequireEqual(t, decErr.Kind, tc.kind)
+			})
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		encryptedText, err := c1.Encrypt([]byte("a"))
+		RequireNoError(t, err)
+		RequireEqual(t, len(encryptedText), 5+minBodySize)
+
+		for _, size := range []int{1, 4, 6, 5 + minBodySize - 1} {
+			_, err := c1.Decrypt(encryptedText[:size])
+			RequireErrorIs(t, err, ErrCiphertextTooShort)
+			RequireErrorIs(t, err, ErrTruncated)
+
+			var decErr *DecryptError
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, KindCorrupt)
+			RequireEqual(t, decErr.Version, byte(1))
+
+			_, err = c1.DecryptReader(bytes.NewReader(encryptedText[:size]))
+			RequireErrorIs(t, err, ErrCiphertextTooShort)
+		}
+
+		// reported even if the key is unknown
+		_, err = c2.Decrypt(encryptedText[:6])
+		RequireErrorIs(t, err, ErrCiphertextTooShort)
+
+		// the shortest valid data
+		dec, err := c1.Decrypt(encryptedText)
+		RequireNoError(t, err)
+		RequireEqual(t, string(dec), "a")
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		for _, text := range append(texts, bytes.Repeat([]byte("a"), 200000)) {
+			encryptedText, err := c1.Encrypt(text)
+			RequireNoError(t, err)
+			RequireNoError(t, c1.Verify(encryptedText))
+
+			if len(text) == 0 {
+				continue
+			}
+
+			tampered := bytes.Clone(encryptedText)
+			tampered[len(tampered)-1] ^= 1
+			err = c1.Verify(tampered)
+			var decErr *DecryptError
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+			// wrong key
+			err = c1broken.Verify(encryptedText)
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+			// truncated
+			err = c1.Verify(encryptedText[:len(encryptedText)-1])
+			RequireError(t, err)
+		}
+
+		// unknown key
+		encryptedText, err := c2.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		RequireErrorIs(t, c1.Verify(encryptedText), ErrUnknownKey)
+		RequireNoError(t, c2.Verify(encryptedText))
+	})
+
+	t.Run("rand", func(t *testing.T) {
+		c := MultiKeyCrypter{}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		// the same random source gives the same ciphertext
+		var encryptedTexts [2][]byte
+		for i := range encryptedTexts {
+			c.Rand = bytes.NewReader(make([]byte, 1024))
+
+			var err error
+			encryptedTexts[i], err = c.Encrypt(texts[2])
+			RequireNoError(t, err)
+		}
+		RequireEqual(t, encryptedTexts[0], encryptedTexts[1])
+
+		text, err := c1.Decrypt(encryptedTexts[0])
+		RequireNoError(t, err)
+		RequireEqual(t, text, texts[2])
+
+		c.Rand = iotest.ErrReader(io.ErrClosedPipe)
+		_, err = c.Encrypt(texts[1])
+		RequireErrorIs(t, err, io.ErrClosedPipe)
+	})
+
+	t.Run("max decrypt size", func(t *testing.T) {
+		for _, size := range []int{13, 200000} {
+			text := bytes.Repeat([]byte("a"), size)
+
+			encryptedText, err := c1.Encrypt(text)
+			RequireNoError(t, err)
+
+			c := MultiKeyCrypter{}
+			c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+			// the limit applies to the encrypted data
+			c.MaxDecryptSize = len(encryptedText)
+			dec, err := c.Decrypt(encryptedText)
+			RequireNoError(t, err)
+			RequireEqual(t, dec, text)
+
+			_, err = c.DecryptBatch([][]byte{encryptedText})
+			RequireNoError(t, err)
+
+			c.MaxDecryptSize = len(encryptedText) - 1
+			_, err = c.Decrypt(encryptedText)
+			RequireErrorIs(t, err, ErrTooLarge)
+
+			_, err = c.DecryptWithAAD(encryptedText, nil)
+			RequireErrorIs(t, err, ErrTooLarge)
+
+			_, err = c.DecryptBatch([][]byte{encryptedText})
+			RequireErrorIs(t, err, ErrTooLarge)
+
+			// streaming applies the limit to the decrypted data
+			c.MaxDecryptSize = size
+			var buf bytes.Buffer
+			n, err := c.DecryptTo(&buf, encryptedText)
+			RequireNoError(t, err)
+			RequireEqual(t, n, int64(size))
+			RequireEqual(t, buf.Bytes(), text)
+
+			c.MaxDecryptSize = size - 1
+			buf.Reset()
+			n, err = c.DecryptTo(&buf, encryptedText)
+			RequireErrorIs(t, err, ErrTooLarge)
+			RequireEqual(t, n, int64(size-1))
+			RequireErrorIs(t, c.Verify(encryptedText), ErrTooLarge)
+
+			// no limit
+			c.MaxDecryptSize = 0
+			RequireNoError(t, c.Verify(encryptedText))
+		}
+
+		// bypassed data is limited as well
+		c := MultiKeyCrypter{MaxDecryptSize: 5}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		r, err := c.DecryptReader(strings.NewReader("#Hello, World!"))
+		RequireNoError(t, err)
+		_, err = io.ReadAll(r)
+		RequireErrorIs(t, err, ErrTooLarge)
+	})
+
+	t.Run("single package", func(t *testing.T) {
+		// values up to 64 KiB are decrypted by the fast path, larger ones by the streaming path
+		for _, size := range []int{1, 64 * 1024, 64*1024 + 1} {
+			text := make([]byte, size)
+			_, _ = rand.Read(text)
+
+			encryptedText, err := c1.Encrypt(text)
+			RequireNoError(t, err)
+
+			decryptedText, err := c1.Decrypt(encryptedText)
+			RequireNoError(t, err)
+			RequireEqual(t, decryptedText, text)
+
+			var streamed bytes.Buffer
+			_, err = c1.DecryptReaderTo(&streamed, bytes.NewReader(encryptedText))
+			RequireNoError(t, err)
+			RequireEqual(t, streamed.Bytes(), text)
+
+			// both paths must report tampering the same way
+			tampered := bytes.Clone(encryptedText)
+			tampered[len(tampered)-1] ^= 0x1
+
+			_, err = c1.Decrypt(tampered)
+			var decErr *DecryptError
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+			_, err = c1.DecryptReaderTo(io.Discard, bytes.NewReader(tampered))
+			RequireTrue(t, errors.As(err, &decErr))
+			RequireEqual(t, decErr.Kind, KindAuthFailed)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		const size = 10<<20 + 123
+
+		plainHash := sha256.New()
+		src := io.TeeReader(io.LimitReader(rand.Reader, size), plainHash)
+
+		var encrypted bytes.Buffer
+		RequireNoError(t, c1.EncryptStream(&encrypted, src))
+
+		encSize, err := c1.EncryptedSize(size)
+		RequireNoError(t, err)
+		RequireEqual(t, encrypted.Len(), encSize)
+
+		decHash := sha256.New()
+		w := &throttledWriter{w: decHash, maxWrites: -1}
+		RequireNoError(t, c1.DecryptStream(w, &encrypted))
+		RequireEqual(t, decHash.Sum(nil), plainHash.Sum(nil))
+
+		// empty stream
+		encrypted.Reset()
+		RequireNoError(t, c1.EncryptStream(&encrypted, bytes.NewReader(nil)))
+		RequireEqual(t, encrypted.Len(), 0)
+
+		// read from
+		for _, size := range []int{0, 1, 64 * 1024, 200000} {
+			text := make([]byte, size)
+			_, _ = rand.Read(text)
+
+			encrypted.Reset()
+			w, err := c1.EncryptWriter(&encrypted)
+			RequireNoError(t, err)
+
+			n, err := w.(io.ReaderFrom).ReadFrom(iotest.HalfReader(bytes.NewReader(text)))
+			RequireNoError(t, err)
+			RequireEqual(t, n, int64(size))
+			RequireNoError(t, w.Close())
+
+			encSize, err := c1.EncryptedSize(size)
+			RequireNoError(t, err)
+			RequireEqual(t, encrypted.Len(), encSize)
+
+			decrypted, err := c1.Decrypt(encrypted.Bytes())
+			RequireNoError(t, err)
+			RequireEqual(t, len(decrypted), size)
+			RequireEqual(t, decrypted, text[:len(decrypted)])
+		}
+
+		// read errors must not finalize the stream
+		encrypted.Reset()
+		src = io.MultiReader(io.LimitReader(rand.Reader, 1<<20), iotest.ErrReader(errTooManyWrites))
+		RequireErrorIs(t, c1.EncryptStream(&encrypted, src), errTooManyWrites)
+		RequireError(t, c1.DecryptStream(io.Discard, &encrypted))
+	})
+
+	t.Run("options", func(t *testing.T) {
+		small := NewMultiKeyCrypter(WithMinVersion(sio.Version10), WithBufSize(100))
+		small.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		// accepts both versions
+		v10 := NewMultiKeyCrypter(WithMinVersion(sio.Version10))
+		v10.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		runCrypterSubtests(t, "small should decrypt self", small, small)
+		runCrypterSubtests(t, "small should decrypt c1", small, &c1)
+		runCrypterSubtests(t, "c1 should not decrypt small", &c1, small)
+		runCrypterSubtests(t, "v10 should decrypt small", v10, small)
+		runCrypterSubtests(t, "v10 should decrypt c1", v10, &c1)
+
+		// stream spanning many chunks
+		text := bytes.Repeat([]byte("0123456789"), 1000)
+		var encrypted bytes.Buffer
+		RequireNoError(t, small.EncryptStream(&encrypted, bytes.NewReader(text)))
+
+		encSize, err := small.EncryptedSize(len(text))
+		RequireNoError(t, err)
+		RequireEqual(t, encrypted.Len(), encSize)
+
+		decrypted, err := small.Decrypt(encrypted.Bytes())
+		RequireNoError(t, err)
+		RequireEqual(t, decrypted, text)
+
+		// sio 1.0 can't detect truncation at a chunk boundary, see WithBufSize
+		header := encSize - 100*(100+32)
+		decrypted, err = small.Decrypt(encrypted.Bytes()[:header+5*(100+32)])
+		RequireNoError(t, err)
+		RequireEqual(t, decrypted, text[:500])
+
+		// but truncation within a chunk is detected
+		_, err = small.Decrypt(encrypted.Bytes()[:header+5*(100+32)+10])
+		RequireError(t, err)
+
+		// invalid combinations
+		for _, opts := range [][]MultiKeyOption{
+			{WithMinVersion(0x42)},
+			{WithBufSize(0)},
+			{WithBufSize(64*1024 + 1)},
+			{WithBufSize(100)}, // unable to decrypt own data
+			{WithMinVersion(sio.Version20), WithBufSize(100)},
+		} {
+			func() {
+				defer func() {
+					RequireTrue(t, recover() != nil)
+				}()
+				NewMultiKeyCrypter(opts...)
+			}()
+		}
+	})
+
+	t.Run("max plaintext size", func(t *testing.T) {
+		small := NewMultiKeyCrypter(WithMinVersion(sio.Version10), WithBufSize(100))
+		small.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		named := MultiKeyCrypter{}
+		named.AddNamedKey("some key", DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		bypassed := MultiKeyCrypter{Bypass: true}
+
+		const pkg = 64*1024 + 32
+		for _, tc := range []struct {
+			name  string
+			c     *MultiKeyCrypter
+			sizes []int
+		}{
+			{"default", &c1, []int{0, 5, 37, 38, 255, 5 + pkg - 1, 5 + pkg, 5 + pkg + 32, 5 + pkg + 33, 5 + 2*pkg, 1 << 20}},
+			{"small", small, []int{0, 37, 38, 137, 138, 169, 170, 171, 255, 5 + 3*132}},
+			{"named", &named, []int{0, 42, 43, 255}},
+			{"bypass", &bypassed, []int{0, 1, 2, 255}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				for _, columnSize := range tc.sizes {
+					n := tc.c.MaxPlaintextSize(columnSize)
+
+					encSize, err := tc.c.EncryptedSize(n)
+					RequireNoError(t, err)
+					if encSize > columnSize {
+						t.Fatalf("column size %d: encrypted size of %d bytes is %d", columnSize, n, encSize)
+					}
+
+					encSize, err = tc.c.EncryptedSize(n + 1)
+					RequireNoError(t, err)
+					if encSize <= columnSize {
+						t.Fatalf("column size %d: %d bytes also fit", columnSize, n+1)
+					}
+				}
+			})
+		}
+
+		RequireEqual(t, c1.MaxPlaintextSize(255), 218)
+		RequireEqual(t, c1.MaxPlaintextSize(5+pkg), 64*1024)
+		RequireEqual(t, c1.MaxPlaintextSize(5+pkg+33), 64*1024+1)
+		RequireEqual(t, c1.MaxPlaintextSize(-1), 0)
+
+		// matches the real output
+		text := bytes.Repeat([]byte("a"), c1.MaxPlaintextSize(255))
+		enc, err := c1.Encrypt(text)
+		RequireNoError(t, err)
+		RequireEqual(t, len(enc), 255)
+	})
+
 	// This should keep working in the future, even if the implementation changes
 	t.Run("regression", func(t *testing.T) {
 		c := MultiKeyCrypter{}
 		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
 
-		text, err := c.Decrypt(DecodeBase64(t, "AQEAAAAgAVcC5tZo0ncayorRSVqXCF8jUrKM1ltvCnsNe2E9aFW5Sa0ZJv1p8+0NZkijKJMX4GlR65R8NcjTxLnXEb17KzksIpBwaq2u7//7KBGsSH0I0DaE/osY0qRMXKFkGnDgOJgWE/GEJO98V/V8mNSFGhC94WNidNgMB+T1QUL0MPVkzlJxAxScEvixMV4Qvn35f0HU91yHyU62ixh/5guxuzXpDpfiKTx6WbFrdjnavYllqmSypR83olhkfCDWob4JKNq4ASNKtD2KWugOFo9g+fREuEY7BBVDA56LdpC5Rqfz+K699X5SHjHrKKwyOrbkkRKFHikvfNc7z302oruLKq5O3ZG/b1q5/33lq4SlKD8QhzYxv42g8aKiAuxu6yricUa13g5FTvEAyBSVKpKiODP30Jenqt3Sjsc/MVrUmpyHti10fS/xZSnuxKeheL30hpifArfqRWAmZS3ByqoYtG9IZex/Coxp2H1B81Cdf3KR4nb3L0BCIGOjzdX6ONdJrk45FVBH4Ez+yvgv1NAexjt6hGfB18B9cYPt4oOLzB/oYFpxSnk2j2BvDNBXvch5c6qakhtTh0J7hle8DqfFQu36SjQr/+8ScfMFceyqoQ+EeIZTMlYlnT2fL86QWYrqMhFtJbrwfn5TpFDL7+30kz5KU7nZk/PX7L3FtmMjnIa5OcXWYy0JJLuyO5seWocOYn4MifSieGiuHgeloIi+aCC9JnfnNNsXw0nub4PfjfvyrXq6R0Rv0RIh+aNTbxf77bekUrLMByP+yYsVP2oPJxublpp5IfbQ2vn/Gc4QjOGiRyaw1ZsiQLnAtspkgQxWxDzJxo49kA=="))
+		text, err := c.Decrypt(DecodeBase64(t, regressionCiphertext))
 		RequireNoError(t, err)
 		RequireEqual(t, string(text), "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Quisque vitae urna non enim ullamcorper convallis at vitae mauris. Aenean elementum sollicitudin malesuada. Quisque eleifend convallis arcu, id convallis est rutrum et. Duis a nisl vel nisl faucibus fringilla in vel eros. Donec urna massa, laoreet at elementum vel, egestas nec mauris. Ut at enim rhoncus, consequat velit a, aliquam odio. Curabitur id molestie leo. Proin id tellus eu justo condimentum aliquam vel ut velit. Nam non sem in turpis rutrum lacinia ut id eros. Phasellus et ipsum ut metus eleifend faucibus. Lorem ipsum dolor sit.")
 	})
 }
+
+// regressionCiphertext is "Lorem ipsum..." encrypted with key1 by an early version of the library
+const regressionCiphertext = "AQEAAAAgAVcC5tZo0ncayorRSVqXCF8jUrKM1ltvCnsNe2E9aFW5Sa0ZJv1p8+0NZkijKJMX4GlR65R8NcjTxLnXEb17KzksIpBwaq2u7//7KBGsSH0I0DaE/osY0qRMXKFkGnDgOJgWE/GEJO98V/V8mNSFGhC94WNidNgMB+T1QUL0MPVkzlJxAxScEvixMV4Qvn35f0HU91yHyU62ixh/5guxuzXpDpfiKTx6WbFrdjnavYllqmSypR83olhkfCDWob4JKNq4ASNKtD2KWugOFo9g+fREuEY7BBVDA56LdpC5Rqfz+K699X5SHjHrKKwyOrbkkRKFHikvfNc7z302oruLKq5O3ZG/b1q5/33lq4SlKD8QhzYxv42g8aKiAuxu6yricUa13g5FTvEAyBSVKpKiODP30Jenqt3Sjsc/MVrUmpyHti10fS/xZSnuxKeheL30hpifArfqRWAmZS3ByqoYtG9IZex/Coxp2H1B81Cdf3KR4nb3L0BCIGOjzdX6ONdJrk45FVBH4Ez+yvgv1NAexjt6hGfB18B9cYPt4oOLzB/oYFpxSnk2j2BvDNBXvch5c6qakhtTh0J7hle8DqfFQu36SjQr/+8ScfMFceyqoQ+EeIZTMlYlnT2fL86QWYrqMhFtJbrwfn5TpFDL7+30kz5KU7nZk/PX7L3FtmMjnIa5OcXWYy0JJLuyO5seWocOYn4MifSieGiuHgeloIi+aCC9JnfnNNsXw0nub4PfjfvyrXq6R0Rv0RIh+aNTbxf77bekUrLMByP+yYsVP2oPJxublpp5IfbQ2vn/Gc4QjOGiRyaw1ZsiQLnAtspkgQxWxDzJxo49kA=="
+
+func FuzzDecrypt(f *testing.F) {
+	c := MultiKeyCrypter{}
+	c.AddKey(0x1, DecodeBase64(f, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c.AddNamedKey("some key", DecodeBase64(f, "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="))
+
+	regression := DecodeBase64(f, regressionCiphertext)
+	for _, n := range []int{0, 1, 4, 5, 6, 21, 37, 38, 53, len(regression) / 2, len(regression) - 1, len(regression)} {
+		f.Add(regression[:n])
+	}
+
+	for _, text := range texts {
+		encryptedText, err := c.Encrypt([]byte(text))
+		RequireNoError(f, err)
+		f.Add(encryptedText)
+
+		encryptedText, err = c.encrypt([]byte(text), []byte("aad"))
+		RequireNoError(f, err)
+		f.Add(encryptedText)
+
+		f.Add(append([]byte{'#'}, text...))
+		f.Add(append([]byte("SLNT"), encryptedText...))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		text, err := c.Decrypt(data)
+		if err != nil {
+			RequireEqual(t, text, []byte(nil))
+		}
+
+		// the streaming path must agree with the in-memory one
+		var buf bytes.Buffer
+		_, streamErr := c.DecryptTo(&buf, data)
+		if (err == nil) != (streamErr == nil) {
+			t.Fatalf("Decrypt error %v, DecryptTo error %v", err, streamErr)
+		}
+		if err == nil {
+			RequireEqual(t, buf.Bytes(), text)
+		}
+
+		if err == nil && len(text) > 0 {
+			encryptedText, err := c.Encrypt(text)
+			RequireNoError(t, err)
+
+			decryptedText, err := c.Decrypt(encryptedText)
+			RequireNoError(t, err)
+			RequireEqual(t, decryptedText, text)
+		}
+	})
+}
+
+func BenchmarkMultikey(b *testing.B) {
+	c := MultiKeyCrypter{}
+	c.AddKey(0x1, make([]byte, 32))
+
+	for _, size := range []int{16, 256, 4 * 1024, 64 * 1024, 1 << 20} {
+		data := make([]byte, size)
+		encData, err := c.Encrypt(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("encrypt/%dB", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Encrypt(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("decrypt/%dB", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Decrypt(encData); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	const batchSize = 1000
+	for _, size := range []int{16, 256, 4 * 1024} {
+		batch := make([][]byte, batchSize)
+		for i := range batch {
+			batch[i] = make([]byte, size)
+		}
+
+		encBatch, err := c.EncryptBatch(batch)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("encrypt loop/%dx%dB", batchSize, size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(batchSize * size))
+
+			for i := 0; i < b.N; i++ {
+				for _, v := range batch {
+					if _, err := c.Encrypt(v); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("encrypt batch/%dx%dB", batchSize, size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(batchSize * size))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := c.EncryptBatch(batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("decrypt loop/%dx%dB", batchSize, size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(batchSize * size))
+
+			for i := 0; i < b.N; i++ {
+				for _, v := range encBatch {
+					if _, err := c.Decrypt(v); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("decrypt batch/%dx%dB", batchSize, size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(batchSize * size))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := c.DecryptBatch(encBatch); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+var errTooManyWrites = errors.New("too many writes")
+
+// throttledWriter simulates a slow consumer. It fails after maxWrites writes, unless maxWrites is negative.
+type throttledWriter struct {
+	w         io.Writer
+	writes    int
+	maxWrites int
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	if w.maxWrites >= 0 && w.writes >= w.maxWrites {
+		return 0, errTooManyWrites
+	}
+
+	w.writes++
+	time.Sleep(time.Millisecond)
+	return w.w.Write(p)
+}
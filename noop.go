@@ -0,0 +1,29 @@
+package silent
+
+import "bytes"
+
+// NoOpCrypter is a [Crypter] implementation that doesn't encrypt anything:
+// Encrypt and Decrypt return the data unchanged.
+//
+// WARNING: it provides no confidentiality at all. Data is stored in plain text.
+//
+// It exists for staged rollouts, where the code paths that use encrypted types are deployed first,
+// and actual encryption is enabled later. Unlike [MultiKeyCrypter.Bypass], it doesn't add any prefix,
+// so the stored data is exactly the same as without silent. It can also be useful in tests.
+type NoOpCrypter struct{}
+
+// Encrypt returns the data unchanged.
+func (NoOpCrypter) Encrypt(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// Decrypt returns a copy of the data. The data is copied, since database drivers may reuse the buffers
+// passed to Scan, and the result must stay valid after that.
+func (NoOpCrypter) Decrypt(data []byte) ([]byte, error) {
+	return bytes.Clone(data), nil
+}
+
+// EncryptedSize returns dataSize, since the data is stored unchanged.
+func (NoOpCrypter) EncryptedSize(dataSize int) (int, error) {
+	return dataSize, nil
+}
@@ -0,0 +1,32 @@
+package silent
+
+import (
+	"testing"
+)
+
+func TestNoOpCrypter(t *testing.T) {
+	c := NoOpCrypter{}
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "c should decrypt self", c, c)
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), "Hello, World!")
+
+		dec, err := c.Decrypt([]byte("stored before encryption was enabled"))
+		RequireNoError(t, err)
+		RequireEqual(t, string(dec), "stored before encryption was enabled")
+	})
+
+	t.Run("decrypt copies", func(t *testing.T) {
+		data := []byte("Hello, World!")
+		dec, err := c.Decrypt(data)
+		RequireNoError(t, err)
+
+		clear(data)
+		RequireEqual(t, string(dec), "Hello, World!")
+	})
+}
@@ -0,0 +1,72 @@
+package silent
+
+import (
+	"bytes"
+	"database/sql/driver"
+)
+
+// NullEncryptedValueFactory is like [EncryptedValueFactory], but distinguishes NULL from an empty value,
+// similar to sql.NullString. NULL is read as Valid=false, while an empty value is read as Valid=true
+// and an empty EncryptedValue. This matters, for example, for upserts that must not overwrite a value with NULL.
+type NullEncryptedValueFactory[T any] struct {
+	EncryptedValue EncryptedValueFactory[T]
+	Valid          bool // Valid is true if EncryptedValue is not NULL
+}
+
+// NullEncryptedValue is a built-in type that uses the same crypter as [EncryptedValue].
+type NullEncryptedValue = NullEncryptedValueFactory[dummy]
+
+// Value is a driver.Valuer implementation. It returns nil if the value is not valid,
+// and the encrypted value otherwise.
+func (v NullEncryptedValueFactory[T]) Value() (driver.Value, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+
+	return v.EncryptedValue.Value()
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the value from the database.
+func (v *NullEncryptedValueFactory[T]) Scan(value interface{}) error {
+	if value == nil {
+		v.EncryptedValue, v.Valid = nil, false
+		return nil
+	}
+
+	if err := v.EncryptedValue.Scan(value); err != nil {
+		v.Valid = false
+		return err
+	}
+
+	v.Valid = true
+	return nil
+}
+
+// MarshalJSON encrypts the value and marshals it into JSON format. Unlike [EncryptedValueFactory.MarshalJSON],
+// it preserves the difference between NULL and empty values: an invalid value is marshaled as null,
+// and a valid empty value as an empty string "". Other values are marshaled the same way as with EncryptedValueFactory.
+func (v NullEncryptedValueFactory[T]) MarshalJSON() ([]byte, error) {
+	if !v.Valid {
+		return []byte("null"), nil
+	}
+
+	return v.EncryptedValue.MarshalJSON()
+}
+
+// UnmarshalJSON decrypts the value from JSON. null results in Valid=false, and anything else, including "", in Valid=true.
+// As usual, fields absent from the JSON are not touched, so in a zero-initialized struct they remain invalid, same as null.
+// This allows APIs to distinguish "don't change" (absent or null) from "clear" ("").
+func (v *NullEncryptedValueFactory[T]) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		v.EncryptedValue, v.Valid = nil, false
+		return nil
+	}
+
+	if err := v.EncryptedValue.UnmarshalJSON(data); err != nil {
+		v.Valid = false
+		return err
+	}
+
+	v.Valid = true
+	return nil
+}
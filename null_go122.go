@@ -0,0 +1,57 @@
+//go:build go1.22
+
+package silent
+
+import (
+	"database/sql"
+	"database/sql/driver"
+)
+
+// EncryptedNullFactory is a nullable encrypted value with the same layout and semantics as sql.Null,
+// available in Go 1.22 and later. NULL is read as Valid=false, while an empty value is read as Valid=true
+// and an empty V. It can be converted to and from sql.Null[EncryptedValueFactory[T]].
+//
+// It is equivalent to [NullEncryptedValueFactory], which remains available for older Go versions.
+type EncryptedNullFactory[T any] sql.Null[EncryptedValueFactory[T]]
+
+// EncryptedNull is a built-in type that uses the same crypter as [EncryptedValue].
+type EncryptedNull = EncryptedNullFactory[dummy]
+
+// Value is a driver.Valuer implementation. It returns nil if the value is not valid,
+// and the encrypted value otherwise.
+func (v EncryptedNullFactory[T]) Value() (driver.Value, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+
+	return v.V.Value()
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the value from the database.
+func (v *EncryptedNullFactory[T]) Scan(value interface{}) error {
+	if value == nil {
+		v.V, v.Valid = nil, false
+		return nil
+	}
+
+	if err := v.V.Scan(value); err != nil {
+		v.Valid = false
+		return err
+	}
+
+	v.Valid = true
+	return nil
+}
+
+// MarshalJSON is the same as [NullEncryptedValueFactory.MarshalJSON]: invalid values are marshaled as null.
+func (v EncryptedNullFactory[T]) MarshalJSON() ([]byte, error) {
+	return NullEncryptedValueFactory[T]{EncryptedValue: v.V, Valid: v.Valid}.MarshalJSON()
+}
+
+// UnmarshalJSON is the same as [NullEncryptedValueFactory.UnmarshalJSON]: null results in Valid=false.
+func (v *EncryptedNullFactory[T]) UnmarshalJSON(data []byte) error {
+	var res NullEncryptedValueFactory[T]
+	err := res.UnmarshalJSON(data)
+	v.V, v.Valid = res.EncryptedValue, res.Valid
+	return err
+}
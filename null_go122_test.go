@@ -0,0 +1,83 @@
+//go:build go1.22
+
+package silent
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+)
+
+func TestEncryptedNull(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	type EncryptedNull1 = EncryptedNullFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	t.Run("null", func(t *testing.T) {
+		enc, err := EncryptedNull1{}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, enc, nil)
+
+		dec := EncryptedNull1{V: EncryptedValue1("stale"), Valid: true}
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Valid, false)
+		RequireEqual(t, len(dec.V), 0)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		enc, err := EncryptedNull1{Valid: true}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, len(enc.([]byte)), 0)
+
+		var dec EncryptedNull1
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Valid, true)
+		RequireEqual(t, len(dec.V), 0)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		enc, err := EncryptedNull1{V: EncryptedValue1("Hello, World!"), Valid: true}.Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedNull1
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Valid, true)
+		RequireEqual(t, dec.V, EncryptedValue1("Hello, World!"))
+
+		// convertible to sql.Null
+		n := sql.Null[EncryptedValue1](dec)
+		RequireEqual(t, n.Valid, true)
+		RequireEqual(t, string(n.V), "Hello, World!")
+	})
+
+	t.Run("decrypt error", func(t *testing.T) {
+		var dec EncryptedNull1
+		RequireError(t, dec.Scan([]byte{7, 1, 2, 3}))
+		RequireEqual(t, dec.Valid, false)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		for _, orig := range []EncryptedNull1{
+			{},
+			{Valid: true},
+			{V: EncryptedValue1("Hello, World!"), Valid: true},
+		} {
+			enc, err := json.Marshal(orig)
+			RequireNoError(t, err)
+
+			dec := EncryptedNull1{V: EncryptedValue1("stale"), Valid: !orig.Valid}
+			RequireNoError(t, json.Unmarshal(enc, &dec))
+			RequireEqual(t, dec.Valid, orig.Valid)
+			RequireEqual(t, string(dec.V), string(orig.V))
+		}
+
+		enc, err := json.Marshal(EncryptedNull1{})
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), "null")
+	})
+}
@@ -0,0 +1,107 @@
+package silent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNullEncryptedValue(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	type NullEncryptedValue1 = NullEncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	t.Run("null", func(t *testing.T) {
+		enc, err := NullEncryptedValue1{}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, enc, nil)
+
+		dec := NullEncryptedValue1{EncryptedValue: EncryptedValue1("stale"), Valid: true}
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Valid, false)
+		RequireEqual(t, len(dec.EncryptedValue), 0)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		enc, err := NullEncryptedValue1{Valid: true}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, len(enc.([]byte)), 0)
+
+		var dec NullEncryptedValue1
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Valid, true)
+		RequireEqual(t, len(dec.EncryptedValue), 0)
+
+		dec = NullEncryptedValue1{}
+		RequireNoError(t, dec.Scan(""))
+		RequireEqual(t, dec.Valid, true)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		enc, err := NullEncryptedValue1{EncryptedValue: EncryptedValue1("Hello, World!"), Valid: true}.Value()
+		RequireNoError(t, err)
+
+		var dec NullEncryptedValue1
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Valid, true)
+		RequireEqual(t, dec.EncryptedValue, EncryptedValue1("Hello, World!"))
+	})
+
+	t.Run("decrypt error", func(t *testing.T) {
+		var dec NullEncryptedValue1
+		RequireError(t, dec.Scan([]byte{7, 1, 2, 3}))
+		RequireEqual(t, dec.Valid, false)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		type patch struct {
+			Username string
+			Token    NullEncryptedValue1
+		}
+
+		// null
+		enc, err := json.Marshal(patch{Username: "john"})
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), `{"Username":"john","Token":null}`)
+
+		dec := patch{Token: NullEncryptedValue1{EncryptedValue: EncryptedValue1("stale"), Valid: true}}
+		RequireNoError(t, json.Unmarshal(enc, &dec))
+		RequireEqual(t, dec.Token.Valid, false)
+		RequireEqual(t, len(dec.Token.EncryptedValue), 0)
+
+		// empty
+		enc, err = json.Marshal(patch{Token: NullEncryptedValue1{Valid: true}})
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), `{"Username":"","Token":""}`)
+
+		dec = patch{}
+		RequireNoError(t, json.Unmarshal(enc, &dec))
+		RequireEqual(t, dec.Token.Valid, true)
+		RequireEqual(t, len(dec.Token.EncryptedValue), 0)
+
+		// value
+		enc, err = json.Marshal(patch{Token: NullEncryptedValue1{EncryptedValue: EncryptedValue1("Hello, World!"), Valid: true}})
+		RequireNoError(t, err)
+		RequireTrue(t, !strings.Contains(string(enc), "Hello"))
+
+		dec = patch{}
+		RequireNoError(t, json.Unmarshal(enc, &dec))
+		RequireEqual(t, dec.Token.Valid, true)
+		RequireEqual(t, string(dec.Token.EncryptedValue), "Hello, World!")
+
+		// absent
+		dec = patch{}
+		RequireNoError(t, json.Unmarshal([]byte(`{"Username":"john"}`), &dec))
+		RequireEqual(t, dec.Token.Valid, false)
+
+		// decrypt error
+		dec = patch{}
+		RequireError(t, json.Unmarshal([]byte(`{"Token":"AQEAAAA="}`), &dec))
+		RequireEqual(t, dec.Token.Valid, false)
+	})
+}
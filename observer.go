@@ -0,0 +1,50 @@
+package silent
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives notifications about encryption and decryption of values, such as [EncryptedValue],
+// for example to export metrics. For encryption, n is the size of the plaintext, and for decryption,
+// the size of the encrypted data. Both are reported on errors as well.
+// Observers are called synchronously, so they must be fast and safe for concurrent use.
+//
+// [SetObserver] sets an observer for the value types. Direct calls of [MultiKeyCrypter] methods
+// are observed by the observer set in its Observer field.
+type Observer interface {
+	ObserveEncrypt(n int, d time.Duration, err error)
+	ObserveDecrypt(n int, d time.Duration, err error)
+}
+
+var observer atomic.Pointer[Observer]
+
+// SetObserver sets the observer for all value types. Pass nil to remove it.
+// When no observer is set, there's no overhead.
+func SetObserver(o Observer) {
+	if o == nil {
+		observer.Store(nil)
+		return
+	}
+	observer.Store(&o)
+}
+
+// observeEncrypt reports an encryption that started at start. It's meant to be deferred, since err is only known at the end.
+func observeEncrypt(o *Observer, n int, start time.Time, err *error) {
+	(*o).ObserveEncrypt(n, time.Since(start), *err)
+}
+
+// observeDecrypt is like observeEncrypt, but for decryption.
+func observeDecrypt(o *Observer, n int, start time.Time, err *error) {
+	(*o).ObserveDecrypt(n, time.Since(start), *err)
+}
+
+// observeEncryptStream is like observeEncrypt, but reports the number of bytes read from the stream so far.
+func observeEncryptStream(o *Observer, r *countingReader, start time.Time, err *error) {
+	(*o).ObserveEncrypt(int(r.n), time.Since(start), *err)
+}
+
+// observeDecryptStream is like observeEncryptStream, but for decryption.
+func observeDecryptStream(o *Observer, r *countingReader, start time.Time, err *error) {
+	(*o).ObserveDecrypt(int(r.n), time.Since(start), *err)
+}
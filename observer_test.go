@@ -0,0 +1,179 @@
+package silent
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	n   int
+	d   time.Duration
+	err error
+}
+
+type recordingObserver struct {
+	mu       sync.Mutex
+	encrypts []observation
+	decrypts []observation
+}
+
+func (o *recordingObserver) ObserveEncrypt(n int, d time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.encrypts = append(o.encrypts, observation{n, d, err})
+}
+
+func (o *recordingObserver) ObserveDecrypt(n int, d time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.decrypts = append(o.decrypts, observation{n, d, err})
+}
+
+func TestObserver(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	t.Run("observed", func(t *testing.T) {
+		o := &recordingObserver{}
+		SetObserver(o)
+		t.Cleanup(func() { SetObserver(nil) })
+
+		enc, err := EncryptedValue1("Hello, World!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedValue1
+		RequireNoError(t, dec.Scan(enc))
+
+		RequireEqual(t, len(o.encrypts), 1)
+		RequireEqual(t, o.encrypts[0].n, 13)
+		RequireNoError(t, o.encrypts[0].err)
+		RequireTrue(t, o.encrypts[0].d >= 0)
+
+		RequireEqual(t, len(o.decrypts), 1)
+		RequireEqual(t, o.decrypts[0].n, len(enc.([]byte)))
+		RequireNoError(t, o.decrypts[0].err)
+
+		// errors are observed as well
+		tampered := append([]byte{}, enc.([]byte)...)
+		tampered[len(tampered)-1] ^= 1
+		RequireError(t, dec.Scan(tampered))
+
+		RequireEqual(t, len(o.decrypts), 2)
+		RequireEqual(t, o.decrypts[1].n, len(tampered))
+		RequireError(t, o.decrypts[1].err)
+	})
+
+	t.Run("aad", func(t *testing.T) {
+		o := &recordingObserver{}
+		SetObserver(o)
+		t.Cleanup(func() { SetObserver(nil) })
+
+		enc, err := WithAAD(EncryptedValue1("Hello"), []byte("row 1")).Value()
+		RequireNoError(t, err)
+
+		v := WithAAD[EncryptedValue1](nil, []byte("row 2"))
+		RequireError(t, v.Scan(enc))
+
+		RequireEqual(t, len(o.encrypts), 1)
+		RequireEqual(t, o.encrypts[0].n, 5)
+		RequireEqual(t, len(o.decrypts), 1)
+		RequireError(t, o.decrypts[0].err)
+	})
+
+	t.Run("direct crypter calls", func(t *testing.T) {
+		o := &recordingObserver{}
+		c := MultiKeyCrypter{Observer: o}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		enc, err := c.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		dec, err := c.Decrypt(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, string(dec), "Hello, World!")
+
+		RequireEqual(t, len(o.encrypts), 1)
+		RequireEqual(t, o.encrypts[0].n, 13)
+		RequireNoError(t, o.encrypts[0].err)
+
+		RequireEqual(t, len(o.decrypts), 1)
+		RequireEqual(t, o.decrypts[0].n, len(enc))
+		RequireNoError(t, o.decrypts[0].err)
+
+		// errors are observed as well
+		tampered := append([]byte{}, enc...)
+		tampered[len(tampered)-1] ^= 1
+		_, err = c.Decrypt(tampered)
+		RequireError(t, err)
+
+		_, err = c.DecryptWithAAD(enc, []byte("row 1"))
+		RequireError(t, err)
+
+		RequireEqual(t, len(o.decrypts), 3)
+		RequireEqual(t, o.decrypts[1].n, len(tampered))
+		RequireErrorIs(t, o.decrypts[1].err, ErrAuthentication)
+		RequireError(t, o.decrypts[2].err)
+
+		disabled := MultiKeyCrypter{Observer: o, DecryptOnly: true}
+		_, err = disabled.Encrypt([]byte("Hello"))
+		RequireErrorIs(t, err, ErrEncryptDisabled)
+
+		RequireEqual(t, len(o.encrypts), 2)
+		RequireEqual(t, o.encrypts[1].n, 5)
+		RequireErrorIs(t, o.encrypts[1].err, ErrEncryptDisabled)
+	})
+
+	t.Run("streams", func(t *testing.T) {
+		o := &recordingObserver{}
+		c := MultiKeyCrypter{Observer: o}
+		c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+		text := bytes.Repeat([]byte("0123456789"), 10000)
+
+		var enc bytes.Buffer
+		RequireNoError(t, c.EncryptStream(&enc, bytes.NewReader(text)))
+
+		var dec bytes.Buffer
+		RequireNoError(t, c.DecryptStream(&dec, bytes.NewReader(enc.Bytes())))
+		RequireEqual(t, dec.Bytes(), text)
+
+		RequireEqual(t, len(o.encrypts), 1)
+		RequireEqual(t, o.encrypts[0].n, len(text))
+		RequireNoError(t, o.encrypts[0].err)
+
+		RequireEqual(t, len(o.decrypts), 1)
+		RequireEqual(t, o.decrypts[0].n, enc.Len())
+		RequireNoError(t, o.decrypts[0].err)
+
+		// truncated stream
+		truncated := enc.Bytes()[:enc.Len()-10]
+		RequireError(t, c.DecryptStream(io.Discard, bytes.NewReader(truncated)))
+
+		RequireEqual(t, len(o.decrypts), 2)
+		RequireEqual(t, o.decrypts[1].n, len(truncated))
+		RequireErrorIs(t, o.decrypts[1].err, ErrTruncated)
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		o := &recordingObserver{}
+		SetObserver(o)
+		SetObserver(nil)
+
+		enc, err := EncryptedValue1("Hello, World!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedValue1
+		RequireNoError(t, dec.Scan(enc))
+
+		RequireEqual(t, len(o.encrypts), 0)
+		RequireEqual(t, len(o.decrypts), 0)
+	})
+}
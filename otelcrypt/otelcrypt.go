@@ -0,0 +1,121 @@
+// Package otelcrypt provides a [silent.Crypter] wrapper that emits an OpenTelemetry span per encrypt/decrypt call.
+// It lives in a separate module to keep OpenTelemetry out of the core module dependencies.
+//
+// Spans carry the size of the data and, when the wrapped crypter can report it, the key ID.
+// Plaintext is never recorded.
+package otelcrypt
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/destel/silent"
+)
+
+// keyIDReporter is implemented by crypters that can report which key was used to encrypt the data.
+type keyIDReporter interface {
+	KeyIDOf(data []byte) (uint32, error)
+}
+
+var _ silent.ContextCrypter = (*Crypter)(nil)
+
+// Crypter wraps another [silent.Crypter] and traces its calls.
+// It implements [silent.ContextCrypter], so spans are linked to the context of the query when it's available.
+type Crypter struct {
+	inner  silent.Crypter
+	tracer trace.Tracer
+}
+
+// New creates a Crypter that traces calls to inner using the provided tracer.
+func New(inner silent.Crypter, tracer trace.Tracer) *Crypter {
+	return &Crypter{
+		inner:  inner,
+		tracer: tracer,
+	}
+}
+
+// Encrypt is the same as [Crypter.EncryptContext] with a background context.
+func (c *Crypter) Encrypt(data []byte) ([]byte, error) {
+	return c.EncryptContext(context.Background(), data)
+}
+
+// Decrypt is the same as [Crypter.DecryptContext] with a background context.
+func (c *Crypter) Decrypt(data []byte) ([]byte, error) {
+	return c.DecryptContext(context.Background(), data)
+}
+
+// EncryptContext encrypts the data within a span started from ctx.
+// The context is propagated to the wrapped crypter if it accepts one.
+func (c *Crypter) EncryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	ctx, span := c.tracer.Start(ctx, "silent.Encrypt", trace.WithAttributes(
+		attribute.Int("silent.plaintext_size", len(data)),
+	))
+	defer span.End()
+
+	var res []byte
+	var err error
+	if cc, ok := c.inner.(silent.ContextCrypter); ok {
+		res, err = cc.EncryptContext(ctx, data)
+	} else {
+		res, err = c.inner.Encrypt(data)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("silent.ciphertext_size", len(res)))
+	c.setKeyID(span, res)
+	return res, nil
+}
+
+// DecryptContext decrypts the data within a span started from ctx.
+// The context is propagated to the wrapped crypter if it accepts one.
+func (c *Crypter) DecryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	ctx, span := c.tracer.Start(ctx, "silent.Decrypt", trace.WithAttributes(
+		attribute.Int("silent.ciphertext_size", len(data)),
+	))
+	defer span.End()
+
+	c.setKeyID(span, data)
+
+	var res []byte
+	var err error
+	if cc, ok := c.inner.(silent.ContextCrypter); ok {
+		res, err = cc.DecryptContext(ctx, data)
+	} else {
+		res, err = c.inner.Decrypt(data)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("silent.plaintext_size", len(res)))
+	return res, nil
+}
+
+func (c *Crypter) setKeyID(span trace.Span, encData []byte) {
+	r, ok := c.inner.(keyIDReporter)
+	if !ok || len(encData) == 0 {
+		return
+	}
+
+	if keyID, err := r.KeyIDOf(encData); err == nil {
+		span.SetAttributes(attribute.Int64("silent.key_id", int64(keyID)))
+	}
+}
+
+// IsBypassed reports whether the wrapped crypter detects the data as produced in bypass mode.
+// See [silent.BypassDetector].
+func (c *Crypter) IsBypassed(data []byte) bool {
+	d, ok := c.inner.(silent.BypassDetector)
+	return ok && d.IsBypassed(data)
+}
@@ -0,0 +1,98 @@
+package otelcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/destel/silent"
+)
+
+func TestCrypter(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString("Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner := silent.MultiKeyCrypter{}
+	inner.AddKey(0x1, key)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c := New(&inner, provider.Tracer("test"))
+
+	text := []byte("Hello, World!")
+	encData, err := c.Encrypt(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decData, err := c.Decrypt(encData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decData, text) {
+		t.Fatalf("expected %q, got %q", text, decData)
+	}
+
+	// tampered data must produce an error span
+	encData[len(encData)-1] ^= 1
+	if _, err := c.Decrypt(encData); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	for i, name := range []string{"silent.Encrypt", "silent.Decrypt", "silent.Decrypt"} {
+		if spans[i].Name() != name {
+			t.Fatalf("expected span %q, got %q", name, spans[i].Name())
+		}
+
+		for _, attr := range spans[i].Attributes() {
+			if bytes.Contains([]byte(attr.Value.Emit()), text) {
+				t.Fatalf("span attribute %s contains plaintext", attr.Key)
+			}
+		}
+	}
+
+	if spans[1].Status().Code == codes.Error {
+		t.Fatalf("expected successful decrypt span")
+	}
+	if spans[2].Status().Code != codes.Error {
+		t.Fatalf("expected failed decrypt span to be marked as error")
+	}
+	if len(spans[2].Events()) == 0 {
+		t.Fatalf("expected error to be recorded on the span")
+	}
+}
+
+func TestRejectBypass(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString("Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner := silent.MultiKeyCrypter{}
+	inner.AddKey(0x1, key)
+
+	provider := sdktrace.NewTracerProvider()
+	c := New(&inner, provider.Tracer("test"))
+
+	type dummy struct{}
+	type EncryptedValue = silent.EncryptedValueFactory[dummy]
+	silent.BindCrypterTo[EncryptedValue](c, silent.WithRejectBypass())
+	t.Cleanup(func() { silent.UnbindCrypter[EncryptedValue]() })
+
+	var dec EncryptedValue
+	if err := dec.Scan([]byte("#Hello, World!")); !errors.Is(err, silent.ErrBypassNotAllowed) {
+		t.Fatalf("expected ErrBypassNotAllowed, got %v", err)
+	}
+}
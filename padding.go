@@ -0,0 +1,143 @@
+package silent
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var ErrInvalidPadding = errors.New("invalid padding")
+
+// PaddingBucket returns the size the plaintext of the given size is padded to. See [PaddingCrypter].
+type PaddingBucket func(size int) int
+
+// PadToMultiple returns a [PaddingBucket] that pads the plaintext to the next multiple of n bytes.
+func PadToMultiple(n int) PaddingBucket {
+	if n < 1 {
+		panic("misconfiguration: padding bucket size must be positive")
+	}
+
+	return func(size int) int {
+		return (size + n - 1) / n * n
+	}
+}
+
+// PadToPowerOfTwo returns a [PaddingBucket] that pads the plaintext to the next power of two, but to at least minSize bytes.
+// This hides the length of the plaintext better than [PadToMultiple], at a cost of up to 2x overhead.
+func PadToPowerOfTwo(minSize int) PaddingBucket {
+	return func(size int) int {
+		size = max(size, minSize)
+		if size <= 1 {
+			return size
+		}
+		return 1 << bits.Len(uint(size-1))
+	}
+}
+
+// paddingHeaderSize is the size of the original length recorded before the data.
+const paddingHeaderSize = 4
+
+// PaddingCrypter is a [Crypter] implementation that pads the data before passing it to the inner crypter,
+// so the length of the encrypted data only reveals the bucket the length of the plaintext falls into.
+// This matters for short values from a small set, such as yes/no answers or ZIP codes,
+// which can otherwise be told apart by length alone.
+// The original length is recorded in a header, which is encrypted and authenticated together with the data.
+//
+// Empty values are not padded and are stored as empty, as usual.
+type PaddingCrypter struct {
+	inner  Crypter
+	bucket PaddingBucket
+}
+
+// NewPaddingCrypter creates a new PaddingCrypter that wraps the inner crypter and pads the data according to bucket,
+// e.g. [PadToMultiple] or [PadToPowerOfTwo].
+func NewPaddingCrypter(inner Crypter, bucket PaddingBucket) *PaddingCrypter {
+	if inner == nil {
+		panic("misconfiguration: inner crypter is nil")
+	}
+	if bucket == nil {
+		panic("misconfiguration: padding bucket is nil")
+	}
+
+	return &PaddingCrypter{inner: inner, bucket: bucket}
+}
+
+// Encrypt pads the data and encrypts the result using the inner crypter.
+func (s *PaddingCrypter) Encrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	paddedSize, err := s.paddedSize(len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// the padding is zeroed by make
+	payload := make([]byte, paddingHeaderSize+paddedSize)
+	binary.LittleEndian.PutUint32(payload, uint32(len(data)))
+	copy(payload[paddingHeaderSize:], data)
+
+	return s.inner.Encrypt(payload)
+}
+
+// Decrypt decrypts the data using the inner crypter and strips the padding.
+func (s *PaddingCrypter) Decrypt(data []byte) ([]byte, error) {
+	payload, err := s.inner.Decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	if len(payload) < paddingHeaderSize {
+		return nil, ErrInvalidPadding
+	}
+
+	size := binary.LittleEndian.Uint32(payload)
+	if uint64(size) > uint64(len(payload)-paddingHeaderSize) {
+		return nil, ErrInvalidPadding
+	}
+
+	return payload[paddingHeaderSize : paddingHeaderSize+int(size)], nil
+}
+
+// EncryptedSize returns the size of the encrypted data, which only depends on the bucket the data size falls into.
+// It requires the inner crypter to report the encrypted size as well, otherwise [ErrSizeNotSupported] is returned.
+func (s *PaddingCrypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	inner, ok := s.inner.(interface{ EncryptedSize(int) (int, error) })
+	if !ok {
+		return 0, ErrSizeNotSupported
+	}
+
+	paddedSize, err := s.paddedSize(dataSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return inner.EncryptedSize(paddingHeaderSize + paddedSize)
+}
+
+// IsBypassed reports whether the inner crypter detects the data as produced in bypass mode. See [BypassDetector].
+func (s *PaddingCrypter) IsBypassed(data []byte) bool {
+	return isBypassed(s.inner, data)
+}
+
+func (s *PaddingCrypter) paddedSize(size int) (int, error) {
+	if uint64(size) > math.MaxUint32 {
+		return 0, errors.New("data is too large to be padded")
+	}
+
+	res := s.bucket(size)
+	if res < size {
+		return 0, errors.New("padding bucket is smaller than the data")
+	}
+	return res, nil
+}
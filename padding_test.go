@@ -0,0 +1,99 @@
+package silent
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaddingCrypter(t *testing.T) {
+	inner1 := MultiKeyCrypter{}
+	inner1.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c1 := NewPaddingCrypter(&inner1, PadToMultiple(16))
+
+	inner2 := MultiKeyCrypter{}
+	inner2.AddKey(1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+	c2 := NewPaddingCrypter(&inner2, PadToMultiple(16))
+
+	c1pow2 := NewPaddingCrypter(&inner1, PadToPowerOfTwo(8))
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "c1 should decrypt self", c1, c1)
+		runCrypterSubtests(t, "c1 should not decrypt c2", c1, c2)
+		runCrypterSubtests(t, "c1pow2 should decrypt self", c1pow2, c1pow2)
+
+		// the padding doesn't depend on the bucketing strategy
+		runCrypterSubtests(t, "c1 should decrypt c1pow2", c1, c1pow2)
+	})
+
+	t.Run("bucket boundaries", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			c       *PaddingCrypter
+			sizes   []int
+			buckets []int
+		}{
+			{"multiple", c1, []int{1, 15, 16, 17, 31, 32, 33}, []int{16, 16, 16, 32, 32, 32, 48}},
+			{"power of two", c1pow2, []int{1, 8, 9, 16, 17, 1000, 1024, 1025}, []int{8, 8, 16, 16, 32, 1024, 1024, 2048}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				for i, size := range tc.sizes {
+					text := bytes.Repeat([]byte("a"), size)
+
+					enc, err := tc.c.Encrypt(text)
+					RequireNoError(t, err)
+
+					// the length only depends on the bucket
+					full, err := tc.c.Encrypt(bytes.Repeat([]byte("b"), tc.buckets[i]))
+					RequireNoError(t, err)
+					RequireEqual(t, len(enc), len(full))
+
+					payload, err := inner1.Decrypt(enc)
+					RequireNoError(t, err)
+					RequireEqual(t, len(payload), paddingHeaderSize+tc.buckets[i])
+
+					dec, err := tc.c.Decrypt(enc)
+					RequireNoError(t, err)
+					RequireTrue(t, bytes.Equal(dec, text))
+				}
+			})
+		}
+	})
+
+	t.Run("invalid padding", func(t *testing.T) {
+		for _, payload := range [][]byte{
+			{1, 0},
+			{5, 0, 0, 0, 'a', 'b', 'c', 'd'},
+		} {
+			enc, err := inner1.Encrypt(payload)
+			RequireNoError(t, err)
+
+			_, err = c1.Decrypt(enc)
+			RequireErrorIs(t, err, ErrInvalidPadding)
+		}
+	})
+
+	t.Run("invalid bucket", func(t *testing.T) {
+		c := NewPaddingCrypter(&inner1, func(size int) int { return size - 1 })
+
+		_, err := c.Encrypt([]byte("Hello, World!"))
+		RequireError(t, err)
+
+		_, err = c.EncryptedSize(13)
+		RequireError(t, err)
+	})
+
+	t.Run("misconfiguration", func(t *testing.T) {
+		for _, f := range []func(){
+			func() { NewPaddingCrypter(nil, PadToMultiple(16)) },
+			func() { NewPaddingCrypter(&inner1, nil) },
+			func() { PadToMultiple(0) },
+		} {
+			func() {
+				defer func() {
+					RequireTrue(t, recover() != nil)
+				}()
+				f()
+			}()
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package silent
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var ErrDecryptUnavailable = errors.New("decryption is unavailable without a private key")
+
+type publicKey struct {
+	pub  *rsa.PublicKey
+	priv *rsa.PrivateKey // nil for encrypt-only keys
+}
+
+// PublicKeyCrypter is a [Crypter] implementation based on hybrid encryption: every value is encrypted with
+// a random AES-256-GCM key, which is in turn encrypted (wrapped) with an RSA public key using RSA-OAEP with SHA-256.
+// Only the public key is needed for encryption, which allows, for example, ingestion services to encrypt data
+// they can't read back, while decryption happens in a hardened service that holds the private key.
+//
+// Like MultiKeyCrypter, it supports multiple keys: the last added key is used for encryption,
+// and the key ID embedded in the encrypted data is used to select the key for decryption.
+// Decryption of data encrypted with a key added by [PublicKeyCrypter.AddPublicKey] fails with [ErrDecryptUnavailable].
+//
+// The overhead is large compared to other crypters: the wrapped key alone is as long as the RSA modulus,
+// e.g. 256 bytes for 2048-bit keys.
+type PublicKeyCrypter struct {
+	keys      map[uint32]publicKey
+	lastKeyID uint32
+
+	// Rand is the source of randomness for data keys, nonces and RSA-OAEP padding. If nil, crypto/rand.Reader is used.
+	// A predictable source outside of tests exposes the data keys, and thus all encrypted data.
+	Rand io.Reader
+}
+
+// NewPublicKeyCrypter creates a new PublicKeyCrypter. Keys must be added before use.
+func NewPublicKeyCrypter() *PublicKeyCrypter {
+	return &PublicKeyCrypter{}
+}
+
+// AddPublicKey adds an encrypt-only key to the crypter.
+// The keyID must be unique and the key must be at least 2048 bits long.
+func (c *PublicKeyCrypter) AddPublicKey(keyID uint32, key *rsa.PublicKey) {
+	c.addKey(keyID, publicKey{pub: key})
+}
+
+// AddPrivateKey adds a key that can be used for both encryption and decryption.
+// The keyID must be unique and the key must be at least 2048 bits long.
+func (c *PublicKeyCrypter) AddPrivateKey(keyID uint32, key *rsa.PrivateKey) {
+	c.addKey(keyID, publicKey{pub: &key.PublicKey, priv: key})
+}
+
+func (c *PublicKeyCrypter) addKey(keyID uint32, key publicKey) {
+	if c.keys == nil {
+		c.keys = make(map[uint32]publicKey)
+	}
+
+	if key.pub.Size() < 2048/8 {
+		panic("misconfiguration: RSA key must be at least 2048 bits long")
+	}
+
+	if _, ok := c.keys[keyID]; ok {
+		panic("misconfiguration: all key ids must be unique")
+	}
+
+	c.keys[keyID] = key
+	c.lastKeyID = keyID
+}
+
+// Encrypt encrypts the data using the last added key.
+// The encrypted data consists of a version byte, a little-endian key ID, the wrapped AES key, a nonce and the sealed data.
+// The version byte and the key ID are authenticated by both RSA-OAEP and AES-GCM.
+func (c *PublicKeyCrypter) Encrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	key, ok := c.keys[c.lastKeyID]
+	if !ok {
+		panic("misconfiguration: no keys were added")
+	}
+
+	var header [aeadHeaderSize]byte
+	header[0] = 1
+	binary.LittleEndian.PutUint32(header[1:], c.lastKeyID)
+
+	random := randReader(c.Rand)
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(random, dataKey); err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), random, key.pub, dataKey, header[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// a random nonce is not strictly needed, since every data key is used only once, but it's cheap
+	nonceSize := aead.NonceSize()
+	res := make([]byte, 0, aeadHeaderSize+len(wrappedKey)+nonceSize+len(data)+aead.Overhead())
+	res = append(res, header[:]...)
+	res = append(res, wrappedKey...)
+	res = res[:len(res)+nonceSize]
+
+	nonce := res[len(res)-nonceSize:]
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(res, nonce, data, header[:]), nil
+}
+
+// Decrypt decrypts the data.
+// The key is automatically selected based on the key ID embedded in the data, and must be a private key.
+func (c *PublicKeyCrypter) Decrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	version := data[0]
+	if version != 1 {
+		return nil, &DecryptError{Kind: KindUnsupportedVersion, Version: version, Cause: ErrUnsupportedVersion}
+	}
+
+	if len(data) < aeadHeaderSize {
+		return nil, &DecryptError{Kind: KindCorrupt, Version: version, Cause: truncatedError(io.ErrUnexpectedEOF)}
+	}
+
+	header := data[:aeadHeaderSize]
+	keyID := binary.LittleEndian.Uint32(header[1:])
+	key, ok := c.keys[keyID]
+	if !ok {
+		return nil, &DecryptError{Kind: KindUnknownKey, Version: version, KeyID: keyID, Cause: ErrUnknownKey}
+	}
+	if key.priv == nil {
+		return nil, &DecryptError{Kind: KindKeyNotAllowed, Version: version, KeyID: keyID, Cause: ErrDecryptUnavailable}
+	}
+
+	const nonceSize, tagSize = 12, 16
+	wrappedKeySize := key.pub.Size()
+	if len(data) < aeadHeaderSize+wrappedKeySize+nonceSize+tagSize {
+		return nil, &DecryptError{Kind: KindCorrupt, Version: version, KeyID: keyID, Cause: truncatedError(io.ErrUnexpectedEOF)}
+	}
+
+	rest := data[aeadHeaderSize:]
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key.priv, rest[:wrappedKeySize], header)
+	if err != nil {
+		return nil, &DecryptError{Kind: KindAuthFailed, Version: version, KeyID: keyID, Cause: err}
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, &DecryptError{Kind: KindCorrupt, Version: version, KeyID: keyID, Cause: err}
+	}
+
+	rest = rest[wrappedKeySize:]
+	res, err := aead.Open(nil, rest[:nonceSize], rest[nonceSize:], header)
+	if err != nil {
+		return nil, &DecryptError{Kind: KindAuthFailed, Version: version, KeyID: keyID, Cause: err}
+	}
+
+	return res, nil
+}
+
+// EncryptedSize returns the size of the encrypted data, which depends on the size of the last added key.
+func (c *PublicKeyCrypter) EncryptedSize(dataSize int) (int, error) {
+	if dataSize == 0 {
+		return 0, nil
+	}
+
+	key, ok := c.keys[c.lastKeyID]
+	if !ok {
+		return 0, errors.New("no keys were added")
+	}
+
+	return aeadHeaderSize + key.pub.Size() + 12 + dataSize + 16, nil
+}
@@ -0,0 +1,136 @@
+package silent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func TestPublicKeyCrypter(t *testing.T) {
+	priv1, err := rsa.GenerateKey(rand.Reader, 2048)
+	RequireNoError(t, err)
+
+	priv2, err := rsa.GenerateKey(rand.Reader, 3072)
+	RequireNoError(t, err)
+
+	// decryption service
+	reader := NewPublicKeyCrypter()
+	reader.AddPrivateKey(0x1, priv1)
+
+	// ingestion service
+	writer := NewPublicKeyCrypter()
+	writer.AddPublicKey(0x1, &priv1.PublicKey)
+
+	// same key id as in reader, but the key itself is different
+	broken := NewPublicKeyCrypter()
+	broken.AddPrivateKey(0x1, priv2)
+
+	// rotated to a new key
+	rotated := NewPublicKeyCrypter()
+	rotated.AddPrivateKey(0x1, priv1)
+	rotated.AddPrivateKey(0x2, priv2)
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		runCrypterSubtests(t, "reader should decrypt self", reader, reader)
+		runCrypterSubtests(t, "reader should decrypt writer", reader, writer)
+		runCrypterSubtests(t, "writer should not decrypt self", writer, writer)
+		runCrypterSubtests(t, "writer should not decrypt reader", writer, reader)
+		runCrypterSubtests(t, "broken should not decrypt writer", broken, writer)
+
+		runCrypterSubtests(t, "rotated should decrypt self", rotated, rotated)
+		runCrypterSubtests(t, "rotated should decrypt writer", rotated, writer)
+		runCrypterSubtests(t, "reader should not decrypt rotated", reader, rotated)
+	})
+
+	t.Run("encrypt", func(t *testing.T) {
+		text := []byte("Hello, World!")
+		encryptedText1, err := writer.Encrypt(text)
+		RequireNoError(t, err)
+
+		if bytes.Contains(encryptedText1, text) {
+			t.Fatalf("encrypted text contains plaintext")
+		}
+
+		encryptedText2, err := writer.Encrypt(text)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Equal(encryptedText1, encryptedText2))
+
+		// arbitrary length
+		long := bytes.Repeat([]byte("0123456789"), 100000)
+		encryptedText, err := writer.Encrypt(long)
+		RequireNoError(t, err)
+		decryptedText, err := reader.Decrypt(encryptedText)
+		RequireNoError(t, err)
+		RequireTrue(t, bytes.Equal(decryptedText, long))
+	})
+
+	t.Run("decrypt errors", func(t *testing.T) {
+		encryptedText, err := writer.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+
+		var decErr *DecryptError
+
+		_, err = writer.Decrypt(encryptedText)
+		RequireErrorIs(t, err, ErrDecryptUnavailable)
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindKeyNotAllowed)
+
+		encryptedText2, err := rotated.Encrypt([]byte("Hello, World!"))
+		RequireNoError(t, err)
+		_, err = reader.Decrypt(encryptedText2)
+		RequireErrorIs(t, err, ErrUnknownKey)
+
+		// the key id is authenticated
+		tampered := bytes.Clone(encryptedText2)
+		tampered[1] = 0x1
+		_, err = rotated.Decrypt(tampered)
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+		// and so is the data
+		tampered = bytes.Clone(encryptedText)
+		tampered[len(tampered)-1] ^= 1
+		_, err = reader.Decrypt(tampered)
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindAuthFailed)
+
+		_, err = reader.Decrypt(encryptedText[:100])
+		RequireTrue(t, errors.As(err, &decErr))
+		RequireEqual(t, decErr.Kind, KindCorrupt)
+		RequireErrorIs(t, err, ErrTruncated)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		type dummyWriter struct{}
+		type WriterValue = EncryptedValueFactory[dummyWriter]
+		BindCrypterTo[WriterValue](writer)
+		t.Cleanup(func() { UnbindCrypter[WriterValue]() })
+
+		type dummyReader struct{}
+		type ReaderValue = EncryptedValueFactory[dummyReader]
+		BindCrypterTo[ReaderValue](reader)
+		t.Cleanup(func() { UnbindCrypter[ReaderValue]() })
+
+		enc, err := WriterValue("Hello, World!").Value()
+		RequireNoError(t, err)
+
+		var w WriterValue
+		RequireErrorIs(t, w.Scan(enc), ErrDecryptUnavailable)
+
+		var r ReaderValue
+		RequireNoError(t, r.Scan(enc))
+		RequireEqual(t, string(r), "Hello, World!")
+	})
+
+	t.Run("key size", func(t *testing.T) {
+		small, err := rsa.GenerateKey(rand.Reader, 1024)
+		RequireNoError(t, err)
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		NewPublicKeyCrypter().AddPublicKey(0x1, &small.PublicKey)
+	})
+}
@@ -0,0 +1,209 @@
+// Package silentcli implements the silent command line tool, which encrypts, decrypts and re-encrypts data
+// with [silent.MultiKeyCrypter]. It's meant for manual migrations and debugging. See cmd/silent for the binary.
+package silentcli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/destel/silent"
+)
+
+const usage = `usage: silent <encrypt|decrypt|rekey> [flags]
+
+Reads data from stdin and writes the result to stdout.
+
+Keys are read from the file given by -key-file, or from the environment variable given by -key-env.
+Keys are separated by newlines or commas, and have the form <id>:<base64 key>. A single key may omit the id,
+in which case its id is 1. Lines starting with # are ignored. The last key is used for encryption.
+
+flags:
+  -key-file string  file with keys
+  -key-env string   environment variable with keys, used if -key-file is not set (default "SILENT_KEYS")
+  -base64           encrypted data is base64-encoded, one value per line, instead of raw binary`
+
+// Run runs the command with the given arguments, which don't include the program name:
+//   - encrypt encrypts stdin
+//   - decrypt decrypts stdin
+//   - rekey re-encrypts stdin with the last key, see [silent.MultiKeyCrypter.ReKey]
+//
+// By default, stdin is processed as a single value in raw binary form. With the -base64 flag, each line is processed
+// as a separate value, and the encrypted values are base64-encoded. Plaintext is never encoded,
+// so values that contain newlines must be processed in raw mode.
+func Run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	cmd := args[0]
+	if cmd != "encrypt" && cmd != "decrypt" && cmd != "rekey" {
+		return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	keyFile := fs.String("key-file", "", "")
+	keyEnv := fs.String("key-env", "SILENT_KEYS", "")
+	b64 := fs.Bool("base64", false, "")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w\n%s", err, usage)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q\n%s", fs.Args(), usage)
+	}
+
+	crypter, err := loadCrypter(*keyFile, *keyEnv)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+
+	if *b64 {
+		err = runLines(cmd, crypter, stdin, w)
+	} else {
+		err = runRaw(cmd, crypter, stdin, w)
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// runRaw processes the whole input as a single value.
+func runRaw(cmd string, crypter *silent.MultiKeyCrypter, r io.Reader, w io.Writer) error {
+	switch cmd {
+	case "encrypt":
+		return crypter.EncryptStream(w, r)
+	case "decrypt":
+		return crypter.DecryptStream(w, r)
+	default:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		res, _, err := crypter.ReKey(data)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(res)
+		return err
+	}
+}
+
+// runLines processes each line of the input as a separate value.
+func runLines(cmd string, crypter *silent.MultiKeyCrypter, r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64<<20)
+
+	for line := 1; sc.Scan(); line++ {
+		res, err := processLine(cmd, crypter, sc.Bytes())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if _, err := w.Write(append(res, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
+
+func processLine(cmd string, crypter *silent.MultiKeyCrypter, line []byte) ([]byte, error) {
+	if cmd == "encrypt" {
+		res, err := crypter.Encrypt(line)
+		if err != nil {
+			return nil, err
+		}
+		return encodeBase64(res), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(line)))
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd == "decrypt" {
+		return crypter.Decrypt(data)
+	}
+
+	res, _, err := crypter.ReKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodeBase64(res), nil
+}
+
+func encodeBase64(data []byte) []byte {
+	res := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(res, data)
+	return res
+}
+
+func loadCrypter(keyFile, keyEnv string) (*silent.MultiKeyCrypter, error) {
+	var keys string
+	switch {
+	case keyFile != "":
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = string(data)
+	case keyEnv != "":
+		keys = os.Getenv(keyEnv)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(keys, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		entries = append(entries, strings.Split(line, ",")...)
+	}
+
+	crypter := &silent.MultiKeyCrypter{}
+	n := 0
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var keyID uint64 = 1
+		if id, key, ok := strings.Cut(entry, ":"); ok {
+			var err error
+			if keyID, err = strconv.ParseUint(strings.TrimSpace(id), 10, 32); err != nil {
+				return nil, fmt.Errorf("invalid key id %q", id)
+			}
+			entry = strings.TrimSpace(key)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(entry)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", keyID, err)
+		}
+
+		if err := crypter.AddKeyErr(uint32(keyID), key); err != nil {
+			return nil, fmt.Errorf("key %d: %w", keyID, err)
+		}
+		n++
+	}
+
+	if n == 0 {
+		return nil, errors.New("no keys provided")
+	}
+	return crypter, nil
+}
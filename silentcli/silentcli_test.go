@@ -0,0 +1,154 @@
+package silentcli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/destel/silent"
+)
+
+const (
+	key1 = "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="
+	key2 = "0XqMfshBExmDODXUVGFNst4HvyBbosb+Nk7sFhSzBoc="
+)
+
+func run(t *testing.T, stdin []byte, args ...string) []byte {
+	t.Helper()
+
+	var stdout bytes.Buffer
+	if err := Run(args, bytes.NewReader(stdin), &stdout); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return stdout.Bytes()
+}
+
+func writeKeyFile(t *testing.T, keys string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	plaintext := []byte("Hello, World!\nsecond line\x00")
+
+	t.Run("raw", func(t *testing.T) {
+		t.Setenv("SILENT_KEYS", key1)
+
+		enc := run(t, plaintext, "encrypt")
+		if bytes.Contains(enc, []byte("Hello")) {
+			t.Fatalf("output contains plaintext")
+		}
+
+		// compatible with the library
+		c := silent.MultiKeyCrypter{}
+		c.AddKey(1, mustDecode(t, key1))
+		dec, err := c.Decrypt(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dec, plaintext) {
+			t.Fatalf("expected %q, got %q", plaintext, dec)
+		}
+
+		dec = run(t, enc, "decrypt")
+		if !bytes.Equal(dec, plaintext) {
+			t.Fatalf("expected %q, got %q", plaintext, dec)
+		}
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		keyFile := writeKeyFile(t, key1+"\n")
+		input := "first\n\nthird\n"
+
+		enc := run(t, []byte(input), "encrypt", "-key-file", keyFile, "-base64")
+		lines := strings.Split(string(enc), "\n")
+		if len(lines) != 4 || lines[1] != "" || lines[3] != "" {
+			t.Fatalf("unexpected output %q", enc)
+		}
+		if _, err := base64.StdEncoding.DecodeString(lines[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		dec := run(t, enc, "decrypt", "-key-file", keyFile, "-base64")
+		if string(dec) != input {
+			t.Fatalf("expected %q, got %q", input, dec)
+		}
+	})
+
+	t.Run("rekey", func(t *testing.T) {
+		t.Setenv("OLD_KEYS", "1:"+key1)
+		t.Setenv("NEW_KEYS", "1:"+key1+", 2:"+key2)
+
+		enc := run(t, plaintext, "encrypt", "-key-env", "OLD_KEYS")
+		rekeyed := run(t, enc, "rekey", "-key-env", "NEW_KEYS")
+
+		c := silent.MultiKeyCrypter{}
+		c.AddKey(2, mustDecode(t, key2))
+		keyID, err := c.KeyIDOf(rekeyed)
+		if err != nil || keyID != 2 {
+			t.Fatalf("expected key 2, got %d, %v", keyID, err)
+		}
+
+		dec := run(t, rekeyed, "decrypt", "-key-env", "NEW_KEYS")
+		if !bytes.Equal(dec, plaintext) {
+			t.Fatalf("expected %q, got %q", plaintext, dec)
+		}
+
+		// base64 mode
+		enc = run(t, []byte("first\nsecond\n"), "encrypt", "-key-env", "OLD_KEYS", "-base64")
+		rekeyed = run(t, enc, "rekey", "-key-env", "NEW_KEYS", "-base64")
+		dec = run(t, rekeyed, "decrypt", "-key-file", writeKeyFile(t, "# new key only, old ones are removed\n2:"+key2), "-base64")
+		if string(dec) != "first\nsecond\n" {
+			t.Fatalf("unexpected output %q", dec)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Setenv("SILENT_KEYS", key1)
+		t.Setenv("BAD_KEYS", "1:notbase64!")
+		t.Setenv("SHORT_KEYS", "1:AAAA")
+		t.Setenv("EMPTY_KEYS", "")
+
+		for _, tc := range []struct {
+			name  string
+			args  []string
+			stdin string
+		}{
+			{"no command", nil, ""},
+			{"unknown command", []string{"frobnicate"}, ""},
+			{"unknown flag", []string{"encrypt", "-nope"}, ""},
+			{"extra args", []string{"encrypt", "file.txt"}, ""},
+			{"no keys", []string{"encrypt", "-key-env", "EMPTY_KEYS"}, ""},
+			{"bad key", []string{"encrypt", "-key-env", "BAD_KEYS"}, ""},
+			{"short key", []string{"encrypt", "-key-env", "SHORT_KEYS"}, ""},
+			{"missing key file", []string{"encrypt", "-key-file", filepath.Join(t.TempDir(), "missing")}, ""},
+			{"bad base64 input", []string{"decrypt", "-base64"}, "not base64!\n"},
+			{"bad ciphertext", []string{"decrypt"}, "\x01garbage"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				var stdout bytes.Buffer
+				if err := Run(tc.args, strings.NewReader(tc.stdin), &stdout); err == nil {
+					t.Fatalf("expected an error")
+				}
+			})
+		}
+	})
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	res, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
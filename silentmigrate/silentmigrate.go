@@ -0,0 +1,251 @@
+// Package silentmigrate provides helpers for rotating keys of encrypted columns in a database/sql database.
+package silentmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/destel/silent"
+)
+
+// Stats reports the progress of [MigrateColumn].
+type Stats struct {
+	Scanned   int64 // rows read
+	Rotated   int64 // rows re-encrypted with the current key and written back
+	Skipped   int64 // rows that are empty or already encrypted with the current key
+	Conflicts int64 // rows modified concurrently; they are left as is and can be rotated by another run
+
+	// LastPK is the primary key of the last processed row, or nil if no rows were processed.
+	// It can be passed to [WithStartAfter] to resume an interrupted migration.
+	LastPK interface{}
+}
+
+type config struct {
+	batchSize       int
+	startAfter      interface{}
+	placeholder     func(n int) string
+	noConflictCheck bool
+	noLimit         bool
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		batchSize:   100,
+		placeholder: func(int) string { return "?" },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Option configures [MigrateColumn].
+type Option func(c *config)
+
+// WithBatchSize sets the number of rows read and written per batch. The default is 100.
+func WithBatchSize(size int) Option {
+	if size < 1 {
+		panic("misconfiguration: batch size must be positive")
+	}
+
+	return func(c *config) {
+		c.batchSize = size
+	}
+}
+
+// WithStartAfter makes the migration start after the row with the given primary key.
+// Together with [Stats.LastPK], this allows to resume an interrupted migration.
+func WithStartAfter(pk interface{}) Option {
+	return func(c *config) {
+		c.startAfter = pk
+	}
+}
+
+// WithDollarPlaceholders makes the generated queries use $1, $2, ... placeholders instead of ?,
+// as required by PostgreSQL drivers.
+func WithDollarPlaceholders() Option {
+	return func(c *config) {
+		c.placeholder = func(n int) string {
+			return "$" + strconv.Itoa(n)
+		}
+	}
+}
+
+// WithoutConflictCheck disables the check that protects concurrently modified rows from being overwritten.
+// Rows are then updated by the primary key only. This is needed for databases that can't compare binary values
+// in WHERE clauses, and is only safe if nothing else writes to the column while the migration is running.
+func WithoutConflictCheck() Option {
+	return func(c *config) {
+		c.noConflictCheck = true
+	}
+}
+
+// WithoutLimit makes the generated queries read the rows without a LIMIT clause, for databases that don't support it.
+// Only the first batch size rows of each query are read then, but the database may still process the rest
+// of the table on every batch, so this is slow for large tables.
+func WithoutLimit() Option {
+	return func(c *config) {
+		c.noLimit = true
+	}
+}
+
+var identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// MigrateColumn re-encrypts the dataCol column of the table with the current encryption key of the crypter,
+// using [silent.MultiKeyCrypter.ReKey]. After it completes, keys that are no longer used can be removed.
+//
+// Rows are read in batches ordered by pkCol, which must be a unique column, typically the primary key.
+// Only rows that were actually re-encrypted are written back, each batch in a short transaction.
+// To avoid overwriting concurrent changes, a row is only updated if its value is still the same as when it was read,
+// so no long-held locks are needed. Rows modified concurrently are counted as conflicts. See also [WithoutConflictCheck].
+//
+// The table and column names are interpolated into the queries, so only plain identifiers are accepted.
+// The migration stops on the first error or when ctx is cancelled. In both cases the stats processed so far
+// are returned, and the migration can be resumed using [WithStartAfter] and [Stats.LastPK].
+func MigrateColumn(ctx context.Context, db *sql.DB, table, pkCol, dataCol string, crypter *silent.MultiKeyCrypter, opts ...Option) (Stats, error) {
+	cfg := newConfig(opts)
+
+	for _, name := range []string{table, pkCol, dataCol} {
+		if !identifierRe.MatchString(name) {
+			return Stats{}, fmt.Errorf("invalid identifier %q", name)
+		}
+	}
+
+	selectFirst, selectNext, update := cfg.queries(table, pkCol, dataCol)
+
+	stats := Stats{LastPK: cfg.startAfter}
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		var rows []row
+		var err error
+		if stats.LastPK == nil {
+			rows, err = readBatch(ctx, db, cfg.batchSize, selectFirst)
+		} else {
+			rows, err = readBatch(ctx, db, cfg.batchSize, selectNext, stats.LastPK)
+		}
+		if err != nil {
+			return stats, err
+		}
+		if len(rows) == 0 {
+			return stats, nil
+		}
+
+		var changed []row
+		for _, r := range rows {
+			res, ok, err := crypter.ReKey(r.data)
+			if err != nil {
+				return stats, fmt.Errorf("row %v: %w", r.pk, err)
+			}
+			if !ok {
+				continue
+			}
+
+			changed = append(changed, row{pk: r.pk, data: r.data, newData: res})
+		}
+
+		rotated, conflicts, err := writeBatch(ctx, db, update, changed, !cfg.noConflictCheck)
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Scanned += int64(len(rows))
+		stats.Rotated += rotated
+		stats.Conflicts += conflicts
+		stats.Skipped += int64(len(rows) - len(changed))
+		stats.LastPK = rows[len(rows)-1].pk
+
+		if len(rows) < cfg.batchSize {
+			return stats, nil
+		}
+	}
+}
+
+// queries returns the queries that read the first batch, read the batch after a given primary key,
+// and write back a re-encrypted row.
+func (c *config) queries(table, pkCol, dataCol string) (selectFirst, selectNext, update string) {
+	// The batch size is interpolated as a literal, since not all databases accept placeholders in LIMIT.
+	limit := fmt.Sprintf(" LIMIT %d", c.batchSize)
+	if c.noLimit {
+		limit = ""
+	}
+
+	p := c.placeholder
+	selectFirst = fmt.Sprintf("SELECT %s, %s FROM %s ORDER BY %s%s", pkCol, dataCol, table, pkCol, limit)
+	selectNext = fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s > %s ORDER BY %s%s", pkCol, dataCol, table, pkCol, p(1), pkCol, limit)
+	update = fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s AND %s = %s", table, dataCol, p(1), pkCol, p(2), dataCol, p(3))
+	if c.noConflictCheck {
+		update = fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s", table, dataCol, p(1), pkCol, p(2))
+	}
+	return selectFirst, selectNext, update
+}
+
+type row struct {
+	pk      interface{}
+	data    []byte
+	newData []byte
+}
+
+func readBatch(ctx context.Context, db *sql.DB, size int, query string, args ...interface{}) ([]row, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []row
+	for len(res) < size && rows.Next() {
+		var r row
+		if err := rows.Scan(&r.pk, &r.data); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+func writeBatch(ctx context.Context, db *sql.DB, query string, rows []row, checkConflicts bool) (rotated, conflicts int64, err error) {
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	for _, r := range rows {
+		args := []interface{}{r.newData, r.pk}
+		if checkConflicts {
+			args = append(args, r.data)
+		}
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, 0, fmt.Errorf("row %v: %w", r.pk, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if n == 0 {
+			conflicts++
+		} else {
+			rotated++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return rotated, conflicts, nil
+}
@@ -0,0 +1,247 @@
+package silentmigrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	_ "github.com/proullon/ramsql/driver"
+
+	"github.com/destel/silent"
+)
+
+func decodeBase64(t *testing.T, s string) []byte {
+	res, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// ramsql can't compare binary values, doesn't support multiple ? placeholders in UPDATE statements,
+// and panics on LIMIT when fewer rows are left than the limit
+var ramsqlOpts = []Option{WithoutConflictCheck(), WithDollarPlaceholders(), WithoutLimit()}
+
+func TestMigrateColumn(t *testing.T) {
+	key1 := decodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")
+	key2 := decodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU=")
+
+	oldCrypter := silent.MultiKeyCrypter{}
+	oldCrypter.AddKey(1, key1)
+
+	crypter := silent.MultiKeyCrypter{}
+	crypter.AddKey(1, key1)
+	crypter.AddKey(2, key2)
+
+	newDB := func(t *testing.T, n int) *sql.DB {
+		db, err := sql.Open("ramsql", t.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		// no primary key: ramsql index lookups fail checkptr validation under -race
+		if _, err := db.Exec("CREATE TABLE users (id BIGINT, token VARBINARY(255))"); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 1; i <= n; i++ {
+			var token []byte
+			switch {
+			case i%5 == 0:
+				// empty
+			case i%3 == 0:
+				// already rotated
+				token, err = crypter.Encrypt([]byte(fmt.Sprint("token ", i)))
+			default:
+				token, err = oldCrypter.Encrypt([]byte(fmt.Sprint("token ", i)))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := db.Exec("INSERT INTO users (id, token) VALUES (?, ?)", i, token); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return db
+	}
+
+	// verify checks that all rows are decryptable and encrypted with the current key
+	verify := func(t *testing.T, db *sql.DB, n int) {
+		rows, err := db.Query("SELECT id, token FROM users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		count := 0
+		for rows.Next() {
+			var id int64
+			var token []byte
+			if err := rows.Scan(&id, &token); err != nil {
+				t.Fatal(err)
+			}
+			count++
+
+			if id%5 == 0 {
+				if len(token) != 0 {
+					t.Errorf("row %d: expected empty token", id)
+				}
+				continue
+			}
+
+			keyID, err := crypter.KeyIDOf(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keyID != 2 {
+				t.Errorf("row %d: expected key 2, got %d", id, keyID)
+			}
+
+			dec, err := crypter.Decrypt(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(dec) != fmt.Sprint("token ", id) {
+				t.Errorf("row %d: unexpected token %q", id, dec)
+			}
+		}
+
+		if count != n {
+			t.Errorf("expected %d rows, got %d", n, count)
+		}
+	}
+
+	t.Run("full", func(t *testing.T) {
+		db := newDB(t, 30)
+
+		stats, err := MigrateColumn(context.Background(), db, "users", "id", "token", &crypter, append(ramsqlOpts, WithBatchSize(7))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 6 empty, 8 already rotated
+		expected := Stats{Scanned: 30, Rotated: 16, Skipped: 14, LastPK: int64(30)}
+		if stats != expected {
+			t.Fatalf("expected %+v, got %+v", expected, stats)
+		}
+		verify(t, db, 30)
+
+		// second run is a no-op
+		stats, err = MigrateColumn(context.Background(), db, "users", "id", "token", &crypter, ramsqlOpts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Scanned != 30 || stats.Rotated != 0 || stats.Skipped != 30 {
+			t.Fatalf("unexpected stats on second run: %+v", stats)
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		db := newDB(t, 30)
+
+		stats, err := MigrateColumn(context.Background(), db, "users", "id", "token", &crypter, append(ramsqlOpts, WithStartAfter(int64(20)))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Scanned != 10 || stats.LastPK != int64(30) {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+
+		stats, err = MigrateColumn(context.Background(), db, "users", "id", "token", &crypter, ramsqlOpts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Scanned != 30 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+		verify(t, db, 30)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		db := newDB(t, 10)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		stats, err := MigrateColumn(ctx, db, "users", "id", "token", &crypter, ramsqlOpts...)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if stats.Scanned != 0 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("empty table", func(t *testing.T) {
+		db := newDB(t, 0)
+
+		stats, err := MigrateColumn(context.Background(), db, "users", "id", "token", &crypter, ramsqlOpts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats != (Stats{}) {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("invalid identifier", func(t *testing.T) {
+		db := newDB(t, 0)
+
+		_, err := MigrateColumn(context.Background(), db, "users; DROP TABLE users", "id", "token", &crypter, ramsqlOpts...)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestQueries(t *testing.T) {
+	cases := []struct {
+		name                string
+		opts                []Option
+		first, next, update string
+	}{
+		{
+			name:   "default",
+			first:  "SELECT id, token FROM users ORDER BY id LIMIT 100",
+			next:   "SELECT id, token FROM users WHERE id > ? ORDER BY id LIMIT 100",
+			update: "UPDATE users SET token = ? WHERE id = ? AND token = ?",
+		},
+		{
+			name:   "options",
+			opts:   []Option{WithBatchSize(7), WithDollarPlaceholders(), WithoutConflictCheck()},
+			first:  "SELECT id, token FROM users ORDER BY id LIMIT 7",
+			next:   "SELECT id, token FROM users WHERE id > $1 ORDER BY id LIMIT 7",
+			update: "UPDATE users SET token = $1 WHERE id = $2",
+		},
+		{
+			name:   "without limit",
+			opts:   []Option{WithoutLimit()},
+			first:  "SELECT id, token FROM users ORDER BY id",
+			next:   "SELECT id, token FROM users WHERE id > ? ORDER BY id",
+			update: "UPDATE users SET token = ? WHERE id = ? AND token = ?",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newConfig(tc.opts)
+
+			first, next, update := cfg.queries("users", "id", "token")
+			if first != tc.first {
+				t.Fatalf("unexpected first query: %q", first)
+			}
+			if next != tc.next {
+				t.Fatalf("unexpected next query: %q", next)
+			}
+			if update != tc.update {
+				t.Fatalf("unexpected update query: %q", update)
+			}
+		})
+	}
+}
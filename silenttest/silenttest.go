@@ -0,0 +1,70 @@
+// Package silenttest provides helpers for testing encrypted columns against a real database/sql flow.
+package silenttest
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/proullon/ramsql/driver"
+
+	"github.com/destel/silent"
+)
+
+var counter atomic.Int64
+
+// NewTestDB opens an in-memory SQL database with a table that has a binary column, suitable for [RoundTripColumn].
+// Every call returns an isolated database, which is closed when the test finishes.
+func NewTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("ramsql", fmt.Sprintf("silenttest-%s-%d", t.Name(), counter.Add(1)))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// The table has no primary key, since index lookups in ramsql fail checkptr validation under -race.
+	_, err = db.Exec("CREATE TABLE silent_values (id BIGINT, value VARBINARY(65535))")
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	return db
+}
+
+// RoundTripColumn inserts the value into a database created by [NewTestDB] and reads it back.
+// It fails the test if the value doesn't decrypt to the original, or if the raw column contains the plaintext.
+// The latter means that crypters in bypass mode do not pass this check.
+func RoundTripColumn[F silent.EncryptedValueFactory[T], T any](t testing.TB, db *sql.DB, value F) {
+	t.Helper()
+
+	id := counter.Add(1)
+	_, err := db.Exec("INSERT INTO silent_values (id, value) VALUES (?, ?)", id, value)
+	if err != nil {
+		t.Fatalf("failed to insert value: %v", err)
+	}
+
+	var dec F
+	err = db.QueryRow("SELECT value FROM silent_values WHERE id = ?", id).Scan(&dec)
+	if err != nil {
+		t.Fatalf("failed to read value: %v", err)
+	}
+
+	if !bytes.Equal(dec, value) {
+		t.Errorf("decrypted value doesn't match the original: expected %q, got %q", []byte(value), []byte(dec))
+	}
+
+	// read the same column again, but without decryption
+	var raw []byte
+	err = db.QueryRow("SELECT value FROM silent_values WHERE id = ?", id).Scan(&raw)
+	if err != nil {
+		t.Fatalf("failed to read raw value: %v", err)
+	}
+
+	if len(value) > 0 && bytes.Contains(raw, value) {
+		t.Errorf("raw column contains plaintext")
+	}
+}
@@ -0,0 +1,29 @@
+package silenttest
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/destel/silent"
+)
+
+func TestRoundTripColumn(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString("Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crypter := silent.MultiKeyCrypter{}
+	crypter.AddKey(0x1, key)
+
+	silent.BindCrypterTo[silent.EncryptedValue](&crypter)
+
+	db := NewTestDB(t)
+	RoundTripColumn(t, db, silent.EncryptedValue("Hello, world!"))
+	RoundTripColumn(t, db, silent.EncryptedValue("some token"))
+	RoundTripColumn(t, db, silent.EncryptedValue(""))
+
+	// databases must be isolated
+	other := NewTestDB(t)
+	RoundTripColumn(t, other, silent.EncryptedValue("Hello, world!"))
+}
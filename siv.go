@@ -0,0 +1,184 @@
+package silent
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+var errSIVOpen = errors.New("siv: message authentication failed")
+
+// sivAEAD implements AES-SIV as specified in RFC 5297, with an optional nonce.
+// The first half of the key is used for S2V (based on AES-CMAC), and the second half for AES-CTR.
+type sivAEAD struct {
+	mac cipher.Block
+	ctr cipher.Block
+}
+
+// newSIV creates AES-SIV for 32, 48 or 64 byte keys, which correspond to AES-128, AES-192 and AES-256.
+func newSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 && len(key) != 48 && len(key) != 64 {
+		return nil, aes.KeySizeError(len(key))
+	}
+
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+
+	ctr, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &sivAEAD{mac: mac, ctr: ctr}, nil
+}
+
+func (s *sivAEAD) NonceSize() int {
+	return 0
+}
+
+func (s *sivAEAD) Overhead() int {
+	return aes.BlockSize
+}
+
+func (s *sivAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	v := s.s2v(plaintext, s.components(additionalData, nonce)...)
+
+	res, out := sliceForAppend(dst, aes.BlockSize+len(plaintext))
+	copy(out, v[:])
+	s.xorKeyStream(out[aes.BlockSize:], plaintext, v)
+	return res
+}
+
+func (s *sivAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errSIVOpen
+	}
+
+	var v [aes.BlockSize]byte
+	copy(v[:], ciphertext)
+	ciphertext = ciphertext[aes.BlockSize:]
+
+	res, out := sliceForAppend(dst, len(ciphertext))
+	s.xorKeyStream(out, ciphertext, v)
+
+	expected := s.s2v(out, s.components(additionalData, nonce)...)
+	if subtle.ConstantTimeCompare(expected[:], v[:]) != 1 {
+		clear(out)
+		return nil, errSIVOpen
+	}
+
+	return res, nil
+}
+
+func (s *sivAEAD) xorKeyStream(dst, src []byte, v [aes.BlockSize]byte) {
+	// clear the 31st and 63rd bits (counting from the right), so the counter can be incremented without carry issues
+	v[8] &= 0x7f
+	v[12] &= 0x7f
+
+	cipher.NewCTR(s.ctr, v[:]).XORKeyStream(dst, src)
+}
+
+// components returns the associated data components of S2V: the associated data, and the nonce if there is one.
+func (s *sivAEAD) components(additionalData, nonce []byte) [][]byte {
+	if len(nonce) == 0 {
+		return [][]byte{additionalData}
+	}
+	return [][]byte{additionalData, nonce}
+}
+
+// s2v implements the S2V construction for the plaintext and any number of associated data components.
+// RFC 5297 treats the nonce as the last of them.
+func (s *sivAEAD) s2v(plaintext []byte, ad ...[]byte) [aes.BlockSize]byte {
+	var zero [aes.BlockSize]byte
+	d := s.cmac(zero[:])
+
+	for _, a := range ad {
+		d = dbl(d)
+		xorBlock(&d, s.cmac(a))
+	}
+
+	var t []byte
+	if len(plaintext) >= aes.BlockSize {
+		t = make([]byte, len(plaintext))
+		copy(t, plaintext)
+		for i := 0; i < aes.BlockSize; i++ {
+			t[len(t)-aes.BlockSize+i] ^= d[i]
+		}
+	} else {
+		d = dbl(d)
+		for i := range plaintext {
+			d[i] ^= plaintext[i]
+		}
+		d[len(plaintext)] ^= 0x80
+		t = d[:]
+	}
+
+	return s.cmac(t)
+}
+
+// cmac implements AES-CMAC as specified in RFC 4493.
+func (s *sivAEAD) cmac(data []byte) [aes.BlockSize]byte {
+	var l [aes.BlockSize]byte
+	s.mac.Encrypt(l[:], l[:])
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	n := (len(data) + aes.BlockSize - 1) / aes.BlockSize
+	if n == 0 {
+		n = 1
+	}
+
+	var last [aes.BlockSize]byte
+	rest := data[(n-1)*aes.BlockSize:]
+	copy(last[:], rest)
+	if len(rest) == aes.BlockSize {
+		xorBlock(&last, k1)
+	} else {
+		last[len(rest)] = 0x80
+		xorBlock(&last, k2)
+	}
+
+	var x [aes.BlockSize]byte
+	for i := 0; i < n-1; i++ {
+		for j := 0; j < aes.BlockSize; j++ {
+			x[j] ^= data[i*aes.BlockSize+j]
+		}
+		s.mac.Encrypt(x[:], x[:])
+	}
+
+	xorBlock(&x, last)
+	s.mac.Encrypt(x[:], x[:])
+	return x
+}
+
+// dbl multiplies the block by x in GF(2^128).
+func dbl(b [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var res [aes.BlockSize]byte
+	carry := b[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		res[i] = b[i]<<1 | b[i+1]>>7
+	}
+	res[aes.BlockSize-1] = b[aes.BlockSize-1]<<1 ^ carry*0x87
+	return res
+}
+
+func xorBlock(dst *[aes.BlockSize]byte, src [aes.BlockSize]byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// sliceForAppend extends the slice by n bytes, and returns the extended slice along with the appended part.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
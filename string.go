@@ -0,0 +1,76 @@
+package silent
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+)
+
+// EncryptedStringFactory is like [EncryptedValueFactory], but is based on string instead of []byte.
+// This allows to assign string literals directly and avoid conversions in the application code.
+//
+// It shares the crypter registry with EncryptedValueFactory: EncryptedStringFactory[T] uses the crypter bound to
+// EncryptedValueFactory[T], and both types produce the same encrypted representation.
+//
+//	type dummy1 struct{} // this won't be used in your code
+//	type MyEncryptedString = EncryptedStringFactory[dummy1]
+//
+//	BindCrypterTo[EncryptedValueFactory[dummy1]](&crypter)
+type EncryptedStringFactory[T any] string
+
+// EncryptedString is a built-in string type that uses the same crypter as [EncryptedValue].
+type EncryptedString = EncryptedStringFactory[dummy]
+
+// String returns a redacted representation of the EncryptedString. See [EncryptedValueFactory.String].
+// Use [EncryptedStringFactory.Reveal] to access the plaintext.
+func (v EncryptedStringFactory[T]) String() string {
+	return fmt.Sprintf("EncryptedString(<redacted %d bytes>)", len(v))
+}
+
+// Reveal returns the plaintext. See [EncryptedValueFactory.Reveal].
+func (v EncryptedStringFactory[T]) Reveal() string {
+	return string(v)
+}
+
+// GoString returns the same redacted representation as [EncryptedStringFactory.String].
+func (v EncryptedStringFactory[T]) GoString() string {
+	return v.String()
+}
+
+// Format is a fmt.Formatter implementation. Verbs such as %d, %x or %q would print the plaintext without it,
+// so all verbs print the same redacted representation as [EncryptedStringFactory.String].
+func (v EncryptedStringFactory[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, v.String())
+}
+
+// MarshalJSON encrypts the value and marshals it into JSON format. See [EncryptedValueFactory.MarshalJSON].
+func (v EncryptedStringFactory[T]) MarshalJSON() ([]byte, error) {
+	return EncryptedValueFactory[T](v).MarshalJSON()
+}
+
+// UnmarshalJSON decrypts the value from JSON.
+func (v *EncryptedStringFactory[T]) UnmarshalJSON(data []byte) error {
+	var res EncryptedValueFactory[T]
+	if err := res.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*v = EncryptedStringFactory[T](res)
+	return nil
+}
+
+// Value is a driver.Valuer implementation. See [EncryptedValueFactory.Value].
+func (v EncryptedStringFactory[T]) Value() (driver.Value, error) {
+	return EncryptedValueFactory[T](v).Value()
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the value from the database.
+func (v *EncryptedStringFactory[T]) Scan(value interface{}) error {
+	var res EncryptedValueFactory[T]
+	if err := res.Scan(value); err != nil {
+		return err
+	}
+
+	*v = EncryptedStringFactory[T](res)
+	return nil
+}
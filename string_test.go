@@ -0,0 +1,91 @@
+package silent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedString(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	type EncryptedString1 = EncryptedStringFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, text := range texts {
+			orig := EncryptedString1(text)
+
+			enc, err := json.Marshal(orig)
+			RequireNoError(t, err)
+
+			if len(text) == 0 {
+				RequireEqual(t, string(enc), `""`)
+			}
+
+			var dec EncryptedString1
+			RequireNoError(t, json.Unmarshal(enc, &dec))
+			RequireEqual(t, dec, orig)
+
+			// same representation as EncryptedValue
+			var decValue EncryptedValue1
+			RequireNoError(t, json.Unmarshal(enc, &decValue))
+			RequireEqual(t, string(decValue), string(orig))
+		}
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		for _, text := range texts {
+			orig := EncryptedString1(text)
+
+			enc, err := orig.Value()
+			RequireNoError(t, err)
+
+			if len(text) == 0 {
+				RequireEqual(t, len(enc.([]byte)), 0)
+			}
+
+			var dec EncryptedString1
+			RequireNoError(t, dec.Scan(enc))
+			RequireEqual(t, dec, orig)
+		}
+
+		var dec EncryptedString1 = "not empty"
+		RequireNoError(t, dec.Scan(nil))
+		RequireEqual(t, dec, EncryptedString1(""))
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		type user struct {
+			Token EncryptedString1
+		}
+
+		for _, format := range []string{"%v", "%+v", "%s", "%#v", "%d", "%x", "%q"} {
+			RequireEqual(t, fmt.Sprintf(format, EncryptedString1("secret")), "EncryptedString(<redacted 6 bytes>)")
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, user{Token: "secret"}), "secret"))
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, user{Token: "secret"}), "736563726574"))
+		}
+	})
+
+	t.Run("Reveal", func(t *testing.T) {
+		RequireEqual(t, EncryptedString1("secret").Reveal(), "secret")
+	})
+
+	t.Run("struct field", func(t *testing.T) {
+		type user struct {
+			Token EncryptedString1
+		}
+
+		enc, err := json.Marshal(user{Token: "secret"})
+		RequireNoError(t, err)
+
+		var dec user
+		RequireNoError(t, json.Unmarshal(enc, &dec))
+		RequireEqual(t, dec.Token, EncryptedString1("secret"))
+	})
+}
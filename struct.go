@@ -0,0 +1,258 @@
+package silent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+)
+
+// EncryptStruct encrypts, in place, the fields of the struct pointed to by v that are tagged with `silent:"encrypt"`.
+// This allows to encrypt data without changing the field types to [EncryptedValue]:
+//
+//	type User struct {
+//		Username string
+//		Token    string `silent:"encrypt"`
+//	}
+//
+// Tagged fields must be exported and have a string or []byte underlying type, or be pointers to such types.
+// Fields of []byte types hold the encrypted data as is, while string fields hold it base64-encoded.
+// Empty values and nil pointers are left as is.
+//
+// Nested structs are processed recursively, including those behind pointers, and elements of slices, arrays and maps.
+// A struct reachable in several ways, e.g. through several pointers, or both as a field and through a pointer to it,
+// is processed only once.
+//
+// Tagged fields are encrypted with the crypter bound to the type of the struct they belong to,
+// using [BindCrypterToType], and with the crypter of [EncryptedValue] if there is none.
+// If neither is bound, [ErrNoCrypter] is returned.
+//
+// On error, v may be left partially encrypted.
+func EncryptStruct(v any) error {
+	return walkStruct(v, true)
+}
+
+// DecryptStruct is the reverse of [EncryptStruct]. It decrypts the tagged fields in place.
+func DecryptStruct(v any) error {
+	return walkStruct(v, false)
+}
+
+func walkStruct(v any, encrypt bool) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a non-nil pointer to a struct, got %T", v)
+	}
+
+	w := structWalker{
+		encrypt: encrypt,
+		visited: make(map[visitKey]bool),
+	}
+	return w.walk(rv.Elem(), rv.Elem().Type().Name())
+}
+
+type structWalker struct {
+	encrypt bool
+	visited map[visitKey]bool // structs that were already processed, to prevent double encryption and cycles
+
+	// copies of map elements are kept alive until the walk is over, so their addresses in visited can't be reused
+	copies []reflect.Value
+}
+
+// visitKey identifies a struct in memory. The address alone is not enough,
+// since a struct and its first field share the same address.
+type visitKey struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+func (w *structWalker) walk(sv reflect.Value, path string) error {
+	t := sv.Type()
+
+	// structs reached from the root pointer are always addressable
+	if sv.CanAddr() {
+		key := visitKey{typ: t, addr: sv.UnsafeAddr()}
+		if w.visited[key] {
+			return nil
+		}
+		w.visited[key] = true
+	}
+
+	// resolved on first use, so structs without tagged fields don't need a crypter
+	var crypter *crypterMapping
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := sv.Field(i)
+		fpath := path + "." + f.Name
+
+		tag, ok := f.Tag.Lookup("silent")
+		if !ok {
+			if f.IsExported() {
+				if err := w.nested(fv, fpath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if tag != "encrypt" {
+			return fmt.Errorf("field %s: unknown silent tag %q", fpath, tag)
+		}
+		if !f.IsExported() {
+			return fmt.Errorf("field %s: unexported fields can't be encrypted", fpath)
+		}
+
+		if crypter == nil {
+			var err error
+			if crypter, err = structCrypter(t); err != nil {
+				return fmt.Errorf("field %s: %w", fpath, err)
+			}
+		}
+
+		if err := w.field(fv, crypter); err != nil {
+			return fmt.Errorf("field %s: %w", fpath, err)
+		}
+	}
+
+	return nil
+}
+
+// nested processes untagged fields, and elements of slices, arrays and maps, that may contain structs.
+func (w *structWalker) nested(fv reflect.Value, path string) error {
+	switch fv.Kind() {
+	case reflect.Struct:
+		return w.walk(fv, path)
+
+	case reflect.Pointer:
+		if fv.IsNil() || fv.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		return w.walk(fv.Elem(), path)
+
+	case reflect.Slice, reflect.Array:
+		if !mayContainStruct(fv.Type().Elem()) {
+			return nil
+		}
+
+		for i := 0; i < fv.Len(); i++ {
+			if err := w.nested(fv.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if !mayContainStruct(fv.Type().Elem()) {
+			return nil
+		}
+
+		// map elements are not addressable, so they are processed as copies, which are stored back
+		iter := fv.MapRange()
+		for iter.Next() {
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			w.copies = append(w.copies, elem)
+
+			if err := w.nested(elem, fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+				return err
+			}
+			fv.SetMapIndex(iter.Key(), elem)
+		}
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// mayContainStruct reports whether values of type t may contain structs processed by [structWalker.nested].
+func mayContainStruct(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
+
+// field encrypts or decrypts a tagged field.
+func (w *structWalker) field(fv reflect.Value, crypter *crypterMapping) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	switch {
+	case fv.Kind() == reflect.String:
+		if fv.Len() == 0 {
+			return nil
+		}
+
+		if w.encrypt {
+			encData, err := crypter.Encrypt([]byte(fv.String()))
+			if err != nil {
+				return err
+			}
+
+			fv.SetString(base64.StdEncoding.EncodeToString(encData))
+			return nil
+		}
+
+		encData, err := base64.StdEncoding.DecodeString(fv.String())
+		if err != nil {
+			return err
+		}
+
+		data, err := crypter.Decrypt(encData)
+		if err != nil {
+			return err
+		}
+
+		fv.SetString(string(data))
+		return nil
+
+	case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+		if fv.Len() == 0 {
+			return nil
+		}
+
+		var res []byte
+		var err error
+		if w.encrypt {
+			res, err = crypter.Encrypt(fv.Bytes())
+		} else {
+			res, err = crypter.Decrypt(fv.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+
+		fv.SetBytes(res)
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported type %s, must be a string or []byte", fv.Type())
+	}
+}
+
+// structCrypter returns the crypter for the tagged fields of a struct type. See [EncryptStruct].
+// Unlike value types, it never panics, since the struct may be processed far from where the crypters are configured.
+func structCrypter(t reflect.Type) (*crypterMapping, error) {
+	r := crypters.Load()
+	if r == nil {
+		return nil, ErrNoCrypter
+	}
+
+	if m := r.lookup(t); m != nil {
+		return m, nil
+	}
+	if m := lookupFor[dummy](r); m != nil {
+		return m, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+
+	return nil, ErrNoCrypter
+}
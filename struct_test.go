@@ -0,0 +1,258 @@
+package silent
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+type structAddress struct {
+	City   string
+	Street string `silent:"encrypt"`
+}
+
+type structCard struct {
+	Number []byte `silent:"encrypt"`
+}
+
+type structUser struct {
+	Username string
+	Token    string  `silent:"encrypt"`
+	Note     *string `silent:"encrypt"`
+	Empty    string  `silent:"encrypt"`
+	Raw      []byte  `silent:"encrypt"`
+
+	Address  structAddress
+	Card     *structCard
+	NoCard   *structCard
+	SameCard *structCard
+	Self     *structUser
+
+	private string
+}
+
+func TestEncryptStruct(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	c2 := MultiKeyCrypter{}
+	c2.AddKey(0x1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+
+	BindCrypterTo[EncryptedValue](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+	newUser := func() *structUser {
+		note := "some note"
+		card := &structCard{Number: []byte("4242 4242 4242 4242")}
+
+		u := &structUser{
+			Username: "john",
+			Token:    "some token",
+			Note:     &note,
+			Raw:      []byte("raw data"),
+			Address:  structAddress{City: "Kyiv", Street: "Khreshchatyk"},
+			Card:     card,
+			SameCard: card,
+			private:  "private",
+		}
+		u.Self = u
+		return u
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		u := newUser()
+		RequireNoError(t, EncryptStruct(u))
+
+		// untagged fields are untouched
+		RequireEqual(t, u.Username, "john")
+		RequireEqual(t, u.Address.City, "Kyiv")
+		RequireEqual(t, u.private, "private")
+		RequireEqual(t, u.Empty, "")
+		RequireTrue(t, u.NoCard == nil)
+
+		// tagged fields are encrypted with the EncryptedValue crypter
+		encToken, err := base64.StdEncoding.DecodeString(u.Token)
+		RequireNoError(t, err)
+		token, err := c1.Decrypt(encToken)
+		RequireNoError(t, err)
+		RequireEqual(t, string(token), "some token")
+
+		RequireTrue(t, *u.Note != "some note")
+		RequireTrue(t, string(u.Raw) != "raw data")
+		RequireTrue(t, u.Address.Street != "Khreshchatyk")
+
+		// shared pointers are encrypted once
+		card, err := c1.Decrypt(u.Card.Number)
+		RequireNoError(t, err)
+		RequireEqual(t, string(card), "4242 4242 4242 4242")
+
+		RequireNoError(t, DecryptStruct(u))
+		expected := newUser()
+		RequireEqual(t, u.Token, expected.Token)
+		RequireEqual(t, *u.Note, *expected.Note)
+		RequireEqual(t, string(u.Raw), string(expected.Raw))
+		RequireEqual(t, u.Address, expected.Address)
+		RequireEqual(t, string(u.Card.Number), string(expected.Card.Number))
+		RequireTrue(t, u.SameCard == u.Card)
+		RequireTrue(t, u.Self == u)
+	})
+
+	t.Run("bound to struct type", func(t *testing.T) {
+		BindCrypterToType(structAddress{}, &c2)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[structAddress]]() })
+
+		u := newUser()
+		RequireNoError(t, EncryptStruct(u))
+
+		encStreet, err := base64.StdEncoding.DecodeString(u.Address.Street)
+		RequireNoError(t, err)
+		street, err := c2.Decrypt(encStreet)
+		RequireNoError(t, err)
+		RequireEqual(t, string(street), "Khreshchatyk")
+
+		_, err = c1.Decrypt(encStreet)
+		RequireError(t, err)
+
+		RequireNoError(t, DecryptStruct(u))
+		RequireEqual(t, u.Address.Street, "Khreshchatyk")
+		RequireEqual(t, u.Token, "some token")
+	})
+
+	t.Run("shared addresses", func(t *testing.T) {
+		type inner struct {
+			Secret string `silent:"encrypt"`
+		}
+		type outer struct {
+			Inner  inner
+			Secret string `silent:"encrypt"`
+		}
+		type root struct {
+			F *inner // points to the first field of G, which has the same address
+			G *outer
+		}
+
+		o := &outer{Inner: inner{Secret: "inner"}, Secret: "outer"}
+		r := &root{F: &o.Inner, G: o}
+		RequireNoError(t, EncryptStruct(r))
+
+		for _, enc := range []string{o.Inner.Secret, o.Secret} {
+			data, err := base64.StdEncoding.DecodeString(enc)
+			RequireNoError(t, err)
+			_, err = c1.Decrypt(data)
+			RequireNoError(t, err)
+		}
+
+		RequireNoError(t, DecryptStruct(r))
+		RequireEqual(t, o.Inner.Secret, "inner")
+		RequireEqual(t, o.Secret, "outer")
+	})
+
+	t.Run("value and pointer", func(t *testing.T) {
+		type holder struct {
+			Name    string
+			Address structAddress
+			Ptr     *structAddress // points to Address, at a non-zero offset
+		}
+
+		h := &holder{Name: "john", Address: structAddress{Street: "Khreshchatyk"}}
+		h.Ptr = &h.Address
+		RequireNoError(t, EncryptStruct(h))
+
+		// encrypted once
+		data, err := base64.StdEncoding.DecodeString(h.Address.Street)
+		RequireNoError(t, err)
+		street, err := c1.Decrypt(data)
+		RequireNoError(t, err)
+		RequireEqual(t, string(street), "Khreshchatyk")
+
+		RequireNoError(t, DecryptStruct(h))
+		RequireEqual(t, h.Address.Street, "Khreshchatyk")
+	})
+
+	t.Run("collections", func(t *testing.T) {
+		type collections struct {
+			Items  []structAddress
+			Cards  []*structCard
+			Array  [2]structAddress
+			ByName map[string]structAddress
+			Nested map[string][]*structCard
+		}
+
+		card := &structCard{Number: []byte("4242 4242 4242 4242")}
+		c := &collections{
+			Items:  []structAddress{{Street: "street 1"}, {Street: "street 2"}},
+			Cards:  []*structCard{card, card},
+			Array:  [2]structAddress{{Street: "street 3"}},
+			ByName: map[string]structAddress{"home": {Street: "street 4"}},
+			Nested: map[string][]*structCard{"cards": {card}},
+		}
+		RequireNoError(t, EncryptStruct(c))
+
+		for _, enc := range []string{c.Items[0].Street, c.Items[1].Street, c.Array[0].Street, c.ByName["home"].Street} {
+			data, err := base64.StdEncoding.DecodeString(enc)
+			RequireNoError(t, err)
+			_, err = c1.Decrypt(data)
+			RequireNoError(t, err)
+		}
+		RequireEqual(t, c.Array[1].Street, "")
+
+		// the shared card is encrypted once
+		number, err := c1.Decrypt(card.Number)
+		RequireNoError(t, err)
+		RequireEqual(t, string(number), "4242 4242 4242 4242")
+
+		RequireNoError(t, DecryptStruct(c))
+		RequireEqual(t, c.Items[0].Street, "street 1")
+		RequireEqual(t, c.Items[1].Street, "street 2")
+		RequireEqual(t, c.Array[0].Street, "street 3")
+		RequireEqual(t, c.ByName["home"].Street, "street 4")
+		RequireEqual(t, string(card.Number), "4242 4242 4242 4242")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		RequireError(t, EncryptStruct(structUser{}))
+		RequireError(t, EncryptStruct((*structUser)(nil)))
+		RequireError(t, EncryptStruct(new(string)))
+
+		type unsupported struct {
+			N int `silent:"encrypt"`
+		}
+		RequireError(t, EncryptStruct(&unsupported{N: 1}))
+
+		type unknownTag struct {
+			S string `silent:"compress"`
+		}
+		RequireError(t, EncryptStruct(&unknownTag{S: "a"}))
+
+		type unexported struct {
+			s string `silent:"encrypt"`
+		}
+		RequireError(t, EncryptStruct(&unexported{s: "a"}))
+
+		// tampered data
+		u := &structUser{Token: "some token"}
+		RequireNoError(t, EncryptStruct(u))
+		u.Token = u.Token[:len(u.Token)-4] + "AAAA"
+		RequireError(t, DecryptStruct(u))
+
+		// not base64
+		u = &structUser{Token: "plain"}
+		RequireError(t, DecryptStruct(u))
+	})
+}
+
+func TestEncryptStructMissingCrypter(t *testing.T) {
+	type secret struct {
+		Token string `silent:"encrypt"`
+	}
+
+	// the crypter of EncryptedValue is not bound
+	v := &secret{Token: "some token"}
+	RequireErrorIs(t, EncryptStruct(v), ErrNoCrypter)
+	RequireEqual(t, v.Token, "some token")
+
+	// structs without tagged fields don't need a crypter
+	type plain struct {
+		City string
+	}
+	RequireNoError(t, EncryptStruct(&plain{City: "Kyiv"}))
+}
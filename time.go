@@ -0,0 +1,91 @@
+package silent
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EncryptedTimeFactory is a generic type factory for encrypted timestamps.
+// The time is formatted as RFC 3339 with nanoseconds, and the result is encrypted with the crypter
+// bound to EncryptedValueFactory[T]. As with [EncryptedValueFactory], T is a unique dummy type used to select the crypter.
+//
+// The zero time is stored as an empty value, and NULL or empty values are read as the zero time.
+// The UTC offset is preserved across a round trip, but the name of the location is not.
+type EncryptedTimeFactory[T any] struct {
+	Time time.Time
+}
+
+// EncryptedTime is a built-in type that uses the same crypter as [EncryptedValue].
+type EncryptedTime = EncryptedTimeFactory[dummy]
+
+// String returns a redacted representation of the EncryptedTime. See [EncryptedValueFactory.String].
+// Use the Time field to access the plaintext.
+func (v EncryptedTimeFactory[T]) String() string {
+	return "EncryptedTime(<redacted>)"
+}
+
+// GoString returns the same redacted representation as [EncryptedTimeFactory.String].
+func (v EncryptedTimeFactory[T]) GoString() string {
+	return v.String()
+}
+
+// Format is a fmt.Formatter implementation. Verbs such as %d would print the internal fields of the Time
+// without it, so all verbs print the same redacted representation as [EncryptedTimeFactory.String].
+func (v EncryptedTimeFactory[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, v.String())
+}
+
+// MarshalJSON encrypts the time and marshals it the same way as [EncryptedValueFactory.MarshalJSON].
+func (v EncryptedTimeFactory[T]) MarshalJSON() ([]byte, error) {
+	return v.encode().MarshalJSON()
+}
+
+// UnmarshalJSON decrypts the time from JSON.
+func (v *EncryptedTimeFactory[T]) UnmarshalJSON(data []byte) error {
+	var enc EncryptedValueFactory[T]
+	if err := enc.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	return v.decode(enc)
+}
+
+// Value is a driver.Valuer implementation. It encrypts the time the same way as [EncryptedValueFactory.Value].
+func (v EncryptedTimeFactory[T]) Value() (driver.Value, error) {
+	return v.encode().Value()
+}
+
+// Scan is a sql.Scanner implementation. It decrypts the time from the database.
+func (v *EncryptedTimeFactory[T]) Scan(value interface{}) error {
+	var enc EncryptedValueFactory[T]
+	if err := enc.Scan(value); err != nil {
+		return err
+	}
+
+	return v.decode(enc)
+}
+
+func (v EncryptedTimeFactory[T]) encode() EncryptedValueFactory[T] {
+	if v.Time.IsZero() {
+		return nil
+	}
+
+	return EncryptedValueFactory[T](v.Time.Format(time.RFC3339Nano))
+}
+
+func (v *EncryptedTimeFactory[T]) decode(data []byte) error {
+	if len(data) == 0 {
+		v.Time = time.Time{}
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		return err
+	}
+
+	v.Time = t
+	return nil
+}
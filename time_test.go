@@ -0,0 +1,79 @@
+package silent
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEncryptedTime(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedTime1 = EncryptedTimeFactory[dummy1]
+	BindCrypterTo[EncryptedValueFactory[dummy1]](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummy1]]() })
+
+	times := []time.Time{
+		time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.FixedZone("", 5*3600+30*60)),
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", -8*3600)),
+	}
+
+	requireSameTime := func(t *testing.T, actual, expected time.Time) {
+		t.Helper()
+		RequireTrue(t, actual.Equal(expected))
+
+		_, actualOffset := actual.Zone()
+		_, expectedOffset := expected.Zone()
+		RequireEqual(t, actualOffset, expectedOffset)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, tm := range times {
+			enc, err := json.Marshal(EncryptedTime1{Time: tm})
+			RequireNoError(t, err)
+
+			var dec EncryptedTime1
+			RequireNoError(t, json.Unmarshal(enc, &dec))
+			requireSameTime(t, dec.Time, tm)
+		}
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		for _, tm := range times {
+			enc, err := EncryptedTime1{Time: tm}.Value()
+			RequireNoError(t, err)
+
+			var dec EncryptedTime1
+			RequireNoError(t, dec.Scan(enc))
+			requireSameTime(t, dec.Time, tm)
+		}
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		for _, format := range []string{"%v", "%+v", "%s", "%#v", "%d", "%x", "%q"} {
+			RequireEqual(t, fmt.Sprintf(format, EncryptedTime1{Time: times[0]}), "EncryptedTime(<redacted>)")
+		}
+	})
+
+	t.Run("zero time", func(t *testing.T) {
+		enc, err := json.Marshal(EncryptedTime1{})
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), `""`)
+
+		encSQL, err := EncryptedTime1{}.Value()
+		RequireNoError(t, err)
+		RequireEqual(t, len(encSQL.([]byte)), 0)
+
+		dec := EncryptedTime1{Time: times[0]}
+		RequireNoError(t, dec.Scan(nil))
+		RequireTrue(t, dec.Time.IsZero())
+
+		dec = EncryptedTime1{Time: times[0]}
+		RequireNoError(t, json.Unmarshal([]byte(`""`), &dec))
+		RequireTrue(t, dec.Time.IsZero())
+	})
+}
@@ -2,11 +2,12 @@ package silent
 
 import (
 	"encoding/base64"
+	"errors"
 	"reflect"
 	"testing"
 )
 
-func DecodeBase64(t *testing.T, s string) []byte {
+func DecodeBase64(t testing.TB, s string) []byte {
 	res, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
 		t.Errorf("error decoding base64: %v", err)
@@ -14,7 +15,7 @@ func DecodeBase64(t *testing.T, s string) []byte {
 	return res
 }
 
-func RequireEqual(t *testing.T, actual, expected any) {
+func RequireEqual(t testing.TB, actual, expected any) {
 	t.Helper()
 
 	ra := reflect.ValueOf(actual)
@@ -38,21 +39,28 @@ func RequireEqual(t *testing.T, actual, expected any) {
 	}
 }
 
-func RequireTrue(t *testing.T, actual bool) {
+func RequireTrue(t testing.TB, actual bool) {
 	t.Helper()
 	RequireEqual(t, actual, true)
 }
 
-func RequireNoError(t *testing.T, err error) {
+func RequireNoError(t testing.TB, err error) {
 	t.Helper()
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
-func RequireError(t *testing.T, err error) {
+func RequireError(t testing.TB, err error) {
 	t.Helper()
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
 }
+
+func RequireErrorIs(t testing.TB, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected error %v, got %v", target, err)
+	}
+}
@@ -2,12 +2,31 @@ package silent
 
 import (
 	"bytes"
+	"crypto/subtle"
+	"database/sql"
 	"database/sql/driver"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode/utf8"
 )
 
+var (
+	ErrPepperMismatch      = errors.New("pepper mismatch")
+	ErrBypassNotAllowed    = errors.New("bypass data is not allowed")
+	ErrBypassUnsupported   = errors.New("crypter can't detect bypass data")
+	ErrCrypterAlreadyBound = errors.New("crypter already bound to this type")
+	ErrNoCrypter           = errors.New("no crypter registered for this type")
+)
+
 // EncryptedValueFactory is a generic type factory for creating custom [EncryptedValue] types.
 // To define a new EncryptedValue type, create a unique dummy type and use it as the generic parameter:
 //
@@ -18,8 +37,31 @@ type EncryptedValueFactory[T any] []byte
 type dummy struct{}
 
 // EncryptedValue is a built-in type that is automatically encrypted when written to, and decrypted when read from, the database.
+//
+// It's the default type, and other built-in types use the same crypter:
+//   - [EncryptedBytes] is another name for EncryptedValue, for code that prefers to be explicit about the underlying type
+//   - [EncryptedString] is based on string instead of []byte
+//   - [EncryptedJSON] encrypts a whole Go value, such as a struct, marshaled to JSON
+//   - [EncryptedInt64] encrypts an integer, without preserving the order of values
+//
+// Note that Value is also the name of the driver.Valuer method, so v.Value() returns the encrypted driver.Value,
+// not the plaintext. Use [EncryptedValueFactory.Reveal] to access the plaintext.
 type EncryptedValue = EncryptedValueFactory[dummy]
 
+// EncryptedBytes is the same type as [EncryptedValue]. Both names can be used interchangeably.
+type EncryptedBytes = EncryptedValue
+
+// Value and the marshaling methods have value receivers, so both EncryptedValue and *EncryptedValue can be passed
+// to database/sql and encoding/json, while Scan and the unmarshaling methods need a pointer, e.g. rows.Scan(&v).
+var (
+	_ driver.Valuer            = EncryptedValue(nil)
+	_ sql.Scanner              = (*EncryptedValue)(nil)
+	_ json.Marshaler           = EncryptedValue(nil)
+	_ json.Unmarshaler         = (*EncryptedValue)(nil)
+	_ encoding.TextMarshaler   = EncryptedValue(nil)
+	_ encoding.TextUnmarshaler = (*EncryptedValue)(nil)
+)
+
 // Crypter is an interface that can be implemented to provide a custom encryption strategy
 type Crypter interface {
 	Encrypt(data []byte) ([]byte, error)
@@ -27,56 +69,662 @@ type Crypter interface {
 }
 
 type crypterMapping struct {
-	Zero    any
-	Crypter Crypter
+	Zero         any
+	Type         reflect.Type
+	Crypter      Crypter
+	Pepper       []byte
+	RejectBypass bool
+	TextEncoding bool
+	JSONEncoding JSONEncoding
+	OnDecrypt    func(info DecryptInfo)
+}
+
+// BypassDetector is implemented by crypters that can tell data produced in bypass mode apart from encrypted data,
+// such as [MultiKeyCrypter]. It is required by [WithRejectBypass].
+// Crypters that wrap other crypters should forward it, otherwise bypass data passes through them unnoticed.
+type BypassDetector interface {
+	IsBypassed(data []byte) bool
+}
+
+// isBypassed reports whether c can detect bypass data and data is such.
+func isBypassed(c Crypter, data []byte) bool {
+	d, ok := c.(BypassDetector)
+	return ok && d.IsBypassed(data)
+}
+
+// crypters holds an immutable registry of mappings. It is replaced as a whole on every change,
+// which keeps lookups lock-free. Changes are serialized with cryptersMu.
+var (
+	crypters   atomic.Pointer[crypterRegistry]
+	cryptersMu sync.Mutex
+)
+
+// crypterMapThreshold is the number of mappings above which lookups go through a map.
+// For smaller registries, a linear scan with type assertions is faster (see BenchmarkGetCrypterFor).
+const crypterMapThreshold = 64
+
+type crypterRegistry struct {
+	list     []crypterMapping
+	byType   map[reflect.Type]*crypterMapping // nil for registries not larger than crypterMapThreshold
+	fallback *crypterMapping                  // used for types without a bound crypter, see SetDefaultCrypter
+	policy   MissingCrypterPolicy
+}
+
+func loadCrypters() []crypterMapping {
+	if r := crypters.Load(); r != nil {
+		return r.list
+	}
+	return nil
+}
+
+// storeCrypters replaces the registry. The map index is only built for large registries. Must be called with cryptersMu held.
+func storeCrypters(list []crypterMapping) {
+	r := newCrypterRegistry(list, len(list) > crypterMapThreshold)
+	if old := crypters.Load(); old != nil {
+		r.fallback, r.policy = old.fallback, old.policy
+	}
+	crypters.Store(r)
+}
+
+// updateCrypters replaces the registry with a modified copy. Must be called with cryptersMu held.
+func updateCrypters(f func(r *crypterRegistry)) {
+	var r crypterRegistry
+	if old := crypters.Load(); old != nil {
+		r = *old
+	}
+	f(&r)
+	crypters.Store(&r)
+}
+
+func newCrypterRegistry(list []crypterMapping, withIndex bool) *crypterRegistry {
+	r := &crypterRegistry{list: list}
+	if withIndex {
+		r.byType = make(map[reflect.Type]*crypterMapping, len(list))
+		for i := range list {
+			r.byType[list[i].Type] = &list[i]
+		}
+	}
+	return r
+}
+
+// MissingCrypterPolicy defines what happens when a value is encrypted or decrypted,
+// but no crypter is bound to its type, and no default crypter is set. See [SetMissingCrypterPolicy].
+type MissingCrypterPolicy int
+
+const (
+	// PolicyPanic makes the operation panic. This is the default, since a missing binding is a misconfiguration.
+	PolicyPanic MissingCrypterPolicy = iota
+	// PolicyError makes the operation fail with [ErrNoCrypter].
+	PolicyError
+)
+
+// SetMissingCrypterPolicy sets the policy for types without a bound crypter.
+// Returning an error instead of panicking can be useful in large applications, where values may be used
+// before all crypters are bound, due to initialization order.
+func SetMissingCrypterPolicy(policy MissingCrypterPolicy) {
+	if policy != PolicyPanic && policy != PolicyError {
+		panic("misconfiguration: unknown missing crypter policy")
+	}
+
+	cryptersMu.Lock()
+	defer cryptersMu.Unlock()
+
+	updateCrypters(func(r *crypterRegistry) {
+		r.policy = policy
+	})
+}
+
+// SetDefaultCrypter sets the crypter used for types without a bound crypter. Pass nil to remove it.
+// When set, it takes precedence over the missing crypter policy (see [SetMissingCrypterPolicy]).
+func SetDefaultCrypter(c Crypter) {
+	cryptersMu.Lock()
+	defer cryptersMu.Unlock()
+
+	updateCrypters(func(r *crypterRegistry) {
+		r.fallback = nil
+		if c != nil {
+			r.fallback = &crypterMapping{Crypter: c}
+		}
+	})
+}
+
+// BindOption configures a binding created by [BindCrypterTo].
+type BindOption func(m *crypterMapping)
+
+// WithPepper sets an application-level pepper for the bound type.
+// The pepper is prepended to the plaintext before encryption, and verified and stripped after decryption.
+// Since it's a part of the plaintext, it's authenticated together with the data,
+// and values encrypted with a different pepper fail to decrypt with [ErrPepperMismatch].
+// Empty values are not peppered and are stored as empty, as usual.
+//
+// Pepper adds some defense for low-entropy values, but it is not a substitute for a good key.
+func WithPepper(pepper []byte) BindOption {
+	return func(m *crypterMapping) {
+		m.Pepper = pepper
+	}
+}
+
+// WithRejectBypass makes the bound type reject data produced in bypass mode (see [MultiKeyCrypter.Bypass]).
+// Decryption of such data fails with [ErrBypassNotAllowed].
+// The crypter must implement [BypassDetector], otherwise binding fails with [ErrBypassUnsupported].
+// This allows the same crypter to be shared between strict production types, and lenient types used for dev fixtures.
+func WithRejectBypass() BindOption {
+	return func(m *crypterMapping) {
+		m.RejectBypass = true
+	}
+}
+
+// WithTextEncoding makes the bound type store the encrypted data in the database as base64-encoded text,
+// instead of raw bytes. This allows to safely use text and varchar columns, which may otherwise mangle
+// binary data, depending on the driver and column encoding. Empty values are stored as empty strings.
+//
+// This only affects the Value and Scan methods. Data stored in binary form can't be read with this option enabled,
+// and vice versa.
+func WithTextEncoding() BindOption {
+	return func(m *crypterMapping) {
+		m.TextEncoding = true
+	}
 }
 
-var crypters []crypterMapping
+// JSONEncoding is the encoding of binary encrypted data in JSON and text. See [WithJSONEncoding].
+type JSONEncoding int
+
+const (
+	// JSONBase64 is the standard base64 encoding, with padding. It's the default.
+	JSONBase64 JSONEncoding = iota
+	// JSONBase64URL is the URL-safe base64 encoding, without padding, as used in JWTs.
+	JSONBase64URL
+	// JSONHex is the lowercase hexadecimal encoding.
+	JSONHex
+)
+
+// WithJSONEncoding sets the encoding of encrypted data that doesn't form a valid UTF-8 string,
+// for the JSON, text and XML representations of the bound type. The default is [JSONBase64].
+// It allows to match the expectations of API consumers, e.g. those that need URL-safe values.
+//
+// Decoding expects the same encoding, so changing it makes previously produced JSON unreadable.
+// The storage format, and the '#' prefixed representation of UTF-8 data, are not affected.
+func WithJSONEncoding(enc JSONEncoding) BindOption {
+	if enc < JSONBase64 || enc > JSONHex {
+		panic("misconfiguration: unknown JSON encoding")
+	}
+
+	return func(m *crypterMapping) {
+		m.JSONEncoding = enc
+	}
+}
 
 // BindCrypterTo binds a crypter instance to a specific EncryptedValue type.
+// It panics if a crypter is already bound to the type.
+// It is safe to call concurrently with other bindings and with encryption/decryption of any values.
 // Example usage:
 //
 //	BindCrypterTo[silent.EncryptedValue](&crypter)
-func BindCrypterTo[F EncryptedValueFactory[T], T any](c Crypter) {
+func BindCrypterTo[F EncryptedValueFactory[T], T any](c Crypter, opts ...BindOption) {
+	if err := BindCrypterToErr[F, T](c, opts...); err != nil {
+		panic("misconfiguration: " + err.Error())
+	}
+}
+
+// BindCrypterToErr is like [BindCrypterTo], but returns [ErrCrypterAlreadyBound] instead of panicking,
+// so callers can decide whether a repeated binding is a problem.
+// It also returns [ErrBypassUnsupported] if [WithRejectBypass] is used with a crypter that can't enforce it.
+func BindCrypterToErr[F EncryptedValueFactory[T], T any](c Crypter, opts ...BindOption) error {
+	cryptersMu.Lock()
+	defer cryptersMu.Unlock()
+
+	list := loadCrypters()
+
 	// this full scan loop is about 10x faster than map in this scenario
-	for _, c := range crypters {
+	for _, c := range list {
 		if _, ok := c.Zero.(T); ok {
-			panic("misconfigurtion: crypter already registered")
+			return ErrCrypterAlreadyBound
 		}
 	}
 
 	var zero T
-	crypters = append(crypters, crypterMapping{
+	m := crypterMapping{
 		Zero:    zero,
+		Type:    reflect.TypeOf((*T)(nil)).Elem(),
 		Crypter: c,
-	})
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+
+	res := make([]crypterMapping, 0, len(list)+1)
+	res = append(res, list...)
+	res = append(res, m)
+	storeCrypters(res)
+	return nil
 }
 
-func getCrypterFor[T any]() Crypter {
-	for _, c := range crypters {
+// BindCrypterToType is like [BindCrypterTo], but the type is specified at runtime, which is useful for frameworks
+// that discover encrypted fields dynamically, e.g. from configuration. The type is identified by a value
+// of the dummy type used as the type parameter of [EncryptedValueFactory], so the following lines are equivalent:
+//
+//	BindCrypterToType(dummy1{}, &crypter)
+//	BindCrypterTo[EncryptedValueFactory[dummy1]](&crypter)
+//
+// Both share the same registry, so a type can't be bound twice, regardless of the function used.
+func BindCrypterToType(zero any, c Crypter, opts ...BindOption) {
+	if err := BindCrypterToTypeErr(zero, c, opts...); err != nil {
+		panic("misconfiguration: " + err.Error())
+	}
+}
+
+// BindCrypterToTypeErr is like [BindCrypterToType], but returns [ErrCrypterAlreadyBound] instead of panicking.
+func BindCrypterToTypeErr(zero any, c Crypter, opts ...BindOption) error {
+	if zero == nil {
+		panic("misconfiguration: zero must not be nil")
+	}
+
+	return bindCrypterToTypes([]reflect.Type{reflect.TypeOf(zero)}, c, opts)
+}
+
+// BindCrypterToAll binds one crypter to several types at once. As with [BindCrypterToType],
+// the types are identified by values of their dummy types:
+//
+//	BindCrypterToAll(&crypter, dummy1{}, dummy2{}, dummy3{})
+//
+// Either all types are bound, or none of them. It panics if a crypter is already bound to any of the types.
+func BindCrypterToAll(c Crypter, zeros ...any) {
+	if err := BindCrypterToAllErr(c, zeros...); err != nil {
+		panic("misconfiguration: " + err.Error())
+	}
+}
+
+// BindCrypterToAllErr is like [BindCrypterToAll], but returns [ErrCrypterAlreadyBound] instead of panicking.
+func BindCrypterToAllErr(c Crypter, zeros ...any) error {
+	typs := make([]reflect.Type, 0, len(zeros))
+	for _, zero := range zeros {
+		if zero == nil {
+			panic("misconfiguration: zero must not be nil")
+		}
+		typs = append(typs, reflect.TypeOf(zero))
+	}
+
+	return bindCrypterToTypes(typs, c, nil)
+}
+
+func bindCrypterToTypes(typs []reflect.Type, c Crypter, opts []BindOption) error {
+	cryptersMu.Lock()
+	defer cryptersMu.Unlock()
+
+	list := loadCrypters()
+	res := make([]crypterMapping, 0, len(list)+len(typs))
+	res = append(res, list...)
+
+	for _, typ := range typs {
+		// res also contains the types bound earlier in this call
+		for _, c := range res {
+			if c.Type == typ {
+				return ErrCrypterAlreadyBound
+			}
+		}
+
+		m := crypterMapping{
+			Zero:    reflect.Zero(typ).Interface(),
+			Type:    typ,
+			Crypter: c,
+		}
+		for _, opt := range opts {
+			opt(&m)
+		}
+		if err := m.validate(); err != nil {
+			return err
+		}
+
+		res = append(res, m)
+	}
+
+	storeCrypters(res)
+	return nil
+}
+
+// UnbindCrypter removes the crypter bound to a specific EncryptedValue type, and reports whether there was one.
+// It is mostly useful for test isolation:
+//
+//	BindCrypterTo[MyEncryptedValue](&crypter)
+//	t.Cleanup(func() { UnbindCrypter[MyEncryptedValue]() })
+func UnbindCrypter[F EncryptedValueFactory[T], T any]() bool {
+	cryptersMu.Lock()
+	defer cryptersMu.Unlock()
+
+	list := loadCrypters()
+	for i, c := range list {
 		if _, ok := c.Zero.(T); ok {
-			return c.Crypter
+			res := make([]crypterMapping, 0, len(list)-1)
+			res = append(res, list[:i]...)
+			res = append(res, list[i+1:]...)
+			storeCrypters(res)
+			return true
+		}
+	}
+
+	return false
+}
+
+func getCrypterFor[T any]() (*crypterMapping, error) {
+	r := crypters.Load()
+	if r == nil {
+		panic("misconfiguration: no crypter registered for this type")
+	}
+
+	if m := lookupFor[T](r); m != nil {
+		return m, nil
+	}
+	return r.missing()
+}
+
+// lookupFor returns the mapping bound to T, or nil if there is none.
+func lookupFor[T any](r *crypterRegistry) *crypterMapping {
+	if r.byType != nil {
+		return r.byType[reflect.TypeOf((*T)(nil)).Elem()]
+	}
+
+	for i := range r.list {
+		if _, ok := r.list[i].Zero.(T); ok {
+			return &r.list[i]
 		}
 	}
+	return nil
+}
+
+// lookup returns the mapping bound to typ, or nil if there is none.
+func (r *crypterRegistry) lookup(typ reflect.Type) *crypterMapping {
+	if r.byType != nil {
+		return r.byType[typ]
+	}
+
+	for i := range r.list {
+		if r.list[i].Type == typ {
+			return &r.list[i]
+		}
+	}
+	return nil
+}
+
+// missing returns the result of a lookup for a type without a bound crypter.
+func (r *crypterRegistry) missing() (*crypterMapping, error) {
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	if r.policy == PolicyError {
+		return nil, ErrNoCrypter
+	}
 
 	panic("misconfiguration: no crypter registered for this type")
 }
 
-// String returns a string representation of the EncryptedValue
+// Encrypt encrypts the data using the bound crypter and options.
+func (m *crypterMapping) Encrypt(data []byte) (res []byte, err error) {
+	if o := observer.Load(); o != nil {
+		defer observeEncrypt(o, len(data), time.Now(), &err)
+	}
+
+	return m.Crypter.Encrypt(m.addPepper(data))
+}
+
+// Decrypt decrypts the data using the bound crypter and options.
+func (m *crypterMapping) Decrypt(data []byte) (res []byte, err error) {
+	if o := observer.Load(); o != nil {
+		defer observeDecrypt(o, len(data), time.Now(), &err)
+	}
+	if m.OnDecrypt != nil {
+		defer m.auditDecrypt(len(data), &err)
+	}
+
+	if err := m.checkBypass(data); err != nil {
+		return nil, err
+	}
+
+	res, err = m.Crypter.Decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.removePepper(res)
+}
+
+// EncryptWithAAD is like [Encrypt], but binds the data to aad. The bound crypter must implement [AADCrypter].
+func (m *crypterMapping) EncryptWithAAD(data, aad []byte) (res []byte, err error) {
+	if o := observer.Load(); o != nil {
+		defer observeEncrypt(o, len(data), time.Now(), &err)
+	}
+
+	c, ok := m.Crypter.(AADCrypter)
+	if !ok {
+		return nil, ErrAADNotSupported
+	}
+
+	return c.EncryptWithAAD(m.addPepper(data), aad)
+}
+
+// DecryptWithAAD is like [Decrypt], but verifies that the data is bound to aad.
+func (m *crypterMapping) DecryptWithAAD(data, aad []byte) (res []byte, err error) {
+	if o := observer.Load(); o != nil {
+		defer observeDecrypt(o, len(data), time.Now(), &err)
+	}
+	if m.OnDecrypt != nil {
+		defer m.auditDecrypt(len(data), &err)
+	}
+
+	c, ok := m.Crypter.(AADCrypter)
+	if !ok {
+		return nil, ErrAADNotSupported
+	}
+
+	if err := m.checkBypass(data); err != nil {
+		return nil, err
+	}
+
+	res, err = c.DecryptWithAAD(data, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.removePepper(res)
+}
+
+// driverValue converts the encrypted data to the representation stored in the database.
+func (m *crypterMapping) driverValue(encData []byte) driver.Value {
+	if !m.TextEncoding {
+		return encData
+	}
+	return base64.StdEncoding.EncodeToString(encData)
+}
+
+// jsonText encodes binary encrypted data for the JSON and text representations.
+func (m *crypterMapping) jsonText(encData []byte) []byte {
+	switch m.JSONEncoding {
+	case JSONHex:
+		res := make([]byte, hex.EncodedLen(len(encData)))
+		hex.Encode(res, encData)
+		return res
+	default:
+		enc := m.base64Encoding()
+		res := make([]byte, enc.EncodedLen(len(encData)))
+		enc.Encode(res, encData)
+		return res
+	}
+}
+
+// jsonData is the reverse of jsonText.
+func (m *crypterMapping) jsonData(text []byte) ([]byte, error) {
+	switch m.JSONEncoding {
+	case JSONHex:
+		res := make([]byte, hex.DecodedLen(len(text)))
+		n, err := hex.Decode(res, text)
+		return res[:n], err
+	default:
+		enc := m.base64Encoding()
+		res := make([]byte, enc.DecodedLen(len(text)))
+		n, err := enc.Decode(res, text)
+		return res[:n], err
+	}
+}
+
+func (m *crypterMapping) base64Encoding() *base64.Encoding {
+	if m.JSONEncoding == JSONBase64URL {
+		return base64.RawURLEncoding
+	}
+	return base64.StdEncoding
+}
+
+// scannedData is the reverse of driverValue.
+func (m *crypterMapping) scannedData(data []byte) ([]byte, error) {
+	if !m.TextEncoding {
+		return data, nil
+	}
+
+	res := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(res, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text-encoded value: %w", err)
+	}
+	return res[:n], nil
+}
+
+func (m *crypterMapping) addPepper(data []byte) []byte {
+	if len(m.Pepper) == 0 {
+		return data
+	}
+
+	res := make([]byte, 0, len(m.Pepper)+len(data))
+	res = append(res, m.Pepper...)
+	res = append(res, data...)
+	return res
+}
+
+func (m *crypterMapping) removePepper(data []byte) ([]byte, error) {
+	if len(m.Pepper) == 0 {
+		return data, nil
+	}
+
+	if len(data) < len(m.Pepper) || subtle.ConstantTimeCompare(data[:len(m.Pepper)], m.Pepper) != 1 {
+		return nil, ErrPepperMismatch
+	}
+	return data[len(m.Pepper):], nil
+}
+
+// validate checks that the crypter supports the options of the mapping.
+func (m *crypterMapping) validate() error {
+	if _, ok := m.Crypter.(BypassDetector); m.RejectBypass && !ok {
+		return ErrBypassUnsupported
+	}
+	return nil
+}
+
+func (m *crypterMapping) checkBypass(data []byte) error {
+	if m.RejectBypass && isBypassed(m.Crypter, data) {
+		return ErrBypassNotAllowed
+	}
+	return nil
+}
+
+// String returns a redacted representation of the EncryptedValue, which only includes the length of the value.
+// This prevents secrets from leaking into logs when values are printed with %v or %s.
+// Use [EncryptedValueFactory.Reveal] or [EncryptedValueFactory.RevealString] to access the plaintext.
 func (v EncryptedValueFactory[T]) String() string {
-	return fmt.Sprintf("EncryptedValue(%s)", string(v))
+	return fmt.Sprintf("EncryptedValue(<redacted %d bytes>)", len(v))
+}
+
+// Reveal returns the plaintext. It's the same as []byte(v), but makes every intentional access
+// to the secret explicit and easy to find, e.g. during code review.
+// The result shares the memory with v, so modifications of one are visible in the other.
+func (v EncryptedValueFactory[T]) Reveal() []byte {
+	return v
+}
+
+// RevealString is like [EncryptedValueFactory.Reveal], but returns the plaintext as a string.
+func (v EncryptedValueFactory[T]) RevealString() string {
+	return string(v)
+}
+
+// Zero overwrites the plaintext with zeros and sets v to nil, so the secret doesn't linger in memory after use.
+//
+// This is a best effort measure, not a guarantee: it only wipes the memory v points to, while the plaintext
+// may have been copied elsewhere, e.g. by conversions to string, by the garbage collector when growing slices,
+// or by the database driver.
+func (v *EncryptedValueFactory[T]) Zero() {
+	clear(*v)
+	*v = nil
+}
+
+// GoString returns the same redacted representation as [EncryptedValueFactory.String], so %#v doesn't leak the plaintext either.
+func (v EncryptedValueFactory[T]) GoString() string {
+	return v.String()
+}
+
+// Format is a fmt.Formatter implementation. Since EncryptedValue is a byte slice, verbs such as %d or %x would print
+// the plaintext without it, so all verbs print the same redacted representation as [EncryptedValueFactory.String].
+func (v EncryptedValueFactory[T]) Format(f fmt.State, verb rune) {
+	io.WriteString(f, v.String())
 }
 
 // MarshalJSON encrypts the value and marshals it into JSON format.
 //   - If the value is empty, it is marshalled as a JSON representation of an empty string ("").
 //   - If the encrypted data forms a valid UTF-8 string, it is marshaled as a string prefixed with '#'.
 //   - Otherwise, the data is marshaled as a base64-encoded string.
+//
+// The '#' prefix is unrelated to the bypass prefix of [MultiKeyCrypter], so values produced in bypass mode get both of them.
 func (v EncryptedValueFactory[T]) MarshalJSON() ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(text) + 3)
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(string(text)); err != nil {
+		return nil, err
+	}
+
+	res := buf.Bytes()
+	res = res[:len(res)-1] // trim trailing newline
+	return res, nil
+}
+
+// UnmarshalJSON decrypts the value from JSON.
+func (v *EncryptedValueFactory[T]) UnmarshalJSON(data []byte) error {
+	if s := string(data); s == `""` || s == `null` {
+		*v = nil
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	return v.UnmarshalText([]byte(text))
+}
+
+// MarshalText is an encoding.TextMarshaler implementation. It encrypts the value and encodes it as text,
+// the same way as [EncryptedValueFactory.MarshalJSON], but without quotes:
+//   - If the value is empty, the text is empty.
+//   - If the encrypted data forms a valid UTF-8 string, it is prefixed with '#'.
+//   - Otherwise, the data is base64-encoded, or encoded as set by [WithJSONEncoding].
+//
+// The format relies on the fact that the alphabets of all supported encodings, e.g. A-Z, a-z, 0-9, '+', '/' and '=' for base64,
+// don't contain '#', so text that starts with '#' is never encoded. Adding an encoding that can produce '#' would break decoding.
+func (v EncryptedValueFactory[T]) MarshalText() ([]byte, error) {
 	if len(v) == 0 {
-		return []byte(`""`), nil
+		return []byte{}, nil
 	}
 
-	crypter := getCrypterFor[T]()
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
 
 	encData, err := crypter.Encrypt(v)
 	if err != nil {
@@ -84,106 +732,118 @@ func (v EncryptedValueFactory[T]) MarshalJSON() ([]byte, error) {
 	}
 
 	if utf8.Valid(encData) {
-		var buf bytes.Buffer
-		buf.Grow(len(encData) + 3)
-
-		enc := json.NewEncoder(&buf)
-		enc.SetEscapeHTML(false)
-
-		err := enc.Encode("#" + string(encData)) // will be encoded as string prepended by #
-		if err != nil {
-			return nil, err
-		}
-
-		res := buf.Bytes()
-		res = res[:len(res)-1] // trim trailing newline
+		res := make([]byte, 0, len(encData)+1)
+		res = append(res, '#')
+		res = append(res, encData...)
 		return res, nil
-	} else {
-		return json.Marshal(encData) // will be encoded as base64
 	}
 
+	return crypter.jsonText(encData), nil
 }
 
-// UnmarshalJSON decrypts the value from JSON.
-func (v *EncryptedValueFactory[T]) UnmarshalJSON(data []byte) error {
-	if s := string(data); s == `""` || s == `null` {
+// UnmarshalText is an encoding.TextUnmarshaler implementation. It decrypts the value from text.
+func (v *EncryptedValueFactory[T]) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
 		*v = nil
 		return nil
 	}
 
-	crypter := getCrypterFor[T]()
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
 
 	var encData []byte
 
-	// string or base64?
-	if len(data) >= 2 && data[1] == '#' {
-		var target string
-		err := json.Unmarshal(data, &target)
-		if err != nil {
-			return err
-		}
-
-		encData = []byte(target[1:])
-	} else {
-		err := json.Unmarshal(data, &encData)
-		if err != nil {
-			return err
-		}
+	// string or base64? See MarshalText for why the prefix is unambiguous
+	if text[0] == '#' {
+		encData = bytes.Clone(text[1:])
+	} else if encData, err = crypter.jsonData(text); err != nil {
+		return err
 	}
 
-	var err error
 	*v, err = crypter.Decrypt(encData)
 	return err
 }
 
-// Value is a driver.Valuer implementation. It encrypts the value and returns a byte slice suitable for database storage.
+// Value is a driver.Valuer implementation. It encrypts the value and returns a byte slice suitable for database storage,
+// or a base64-encoded string if the bound crypter uses [WithTextEncoding].
 func (v EncryptedValueFactory[T]) Value() (driver.Value, error) {
 	if len(v) == 0 {
+		// empty values don't need a crypter, but the text encoding of the bound type is still respected
+		if r := crypters.Load(); r != nil {
+			if m := lookupFor[T](r); m != nil {
+				return m.driverValue([]byte{}), nil
+			}
+		}
 		return []byte{}, nil
 	}
 
-	crypter := getCrypterFor[T]()
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return nil, err
+	}
 
 	encData, err := crypter.Encrypt(v)
-	return encData, err
+	if err != nil {
+		return nil, err
+	}
+	return crypter.driverValue(encData), nil
 }
 
 // Scan is a sql.Scanner implementation. It decrypts the value from the database.
 func (v *EncryptedValueFactory[T]) Scan(value interface{}) error {
-	crypter := getCrypterFor[T]()
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
 
-	switch t := value.(type) {
-	case nil:
+	encData, ok := scanBytes(value)
+	if !ok {
+		return fmt.Errorf("unable to scan %T into EncryptedValue", value)
+	}
+
+	if len(encData) == 0 {
 		*v = nil
 		return nil
-	case []byte:
-		if len(t) == 0 {
-			*v = nil
-			return nil
-		}
+	}
 
-		data, err := crypter.Decrypt(t)
-		if err != nil {
-			return err
-		}
+	encData, err = crypter.scannedData(encData)
+	if err != nil {
+		return err
+	}
 
-		*v = data
-		return nil
+	data, err := crypter.Decrypt(encData)
+	if err != nil {
+		return err
+	}
 
-	case string:
-		if t == "" {
-			*v = nil
-			return nil
-		}
+	*v = data
+	return nil
+}
 
-		data, err := crypter.Decrypt([]byte(t))
-		if err != nil {
-			return err
-		}
+// scanBytes extracts the encrypted data from a value passed to sql.Scanner.
+// Besides []byte and string, it accepts sql.RawBytes, as well as any other types with string or []byte underlying types,
+// which some drivers return. Byte slices of such types are copied, since drivers may reuse them, as with RawBytes.
+func scanBytes(value interface{}) ([]byte, bool) {
+	switch t := value.(type) {
+	case nil:
+		return nil, true
+	case []byte:
+		return t, true
+	case string:
+		return []byte(t), true
+	case sql.RawBytes:
+		return bytes.Clone(t), true
+	}
 
-		*v = data
-		return nil
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), true
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return bytes.Clone(rv.Bytes()), true
 	default:
-		return fmt.Errorf("unable to scan %T into EncryptedValue", value)
+		return nil, false
 	}
 }
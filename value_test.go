@@ -2,10 +2,22 @@ package silent
 
 import (
 	"bytes"
+	"crypto/rand"
 	"database/sql"
 	"database/sql/driver"
+	"encoding"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	_ "github.com/proullon/ramsql/driver"
+	"gopkg.in/yaml.v3"
 )
 
 func runValueSubtestsJSON[F EncryptedValueFactory[T], T any](t *testing.T, name string) {
@@ -30,13 +42,55 @@ func runValueSubtestsJSON[F EncryptedValueFactory[T], T any](t *testing.T, name
 	})
 }
 
+func runValueSubtestsText[F EncryptedValueFactory[T], T any](t *testing.T, name string) {
+	t.Run(name, func(t *testing.T) {
+		for _, text := range texts {
+			orig := F(text)
+
+			enc, err := any(orig).(encoding.TextMarshaler).MarshalText()
+			RequireNoError(t, err)
+
+			if len(text) == 0 {
+				RequireEqual(t, len(enc), 0)
+			}
+
+			var dec F
+			err = any(&dec).(encoding.TextUnmarshaler).UnmarshalText(enc)
+			RequireNoError(t, err)
+
+			RequireEqual(t, dec, orig)
+		}
+	})
+}
+
+func runValueSubtestsXML[F EncryptedValueFactory[T], T any](t *testing.T, name string) {
+	type record struct {
+		Data F `xml:"data"`
+	}
+
+	t.Run(name, func(t *testing.T) {
+		for _, text := range texts {
+			orig := record{Data: F(text)}
+
+			enc, err := xml.Marshal(orig)
+			RequireNoError(t, err)
+
+			var dec record
+			err = xml.Unmarshal(enc, &dec)
+			RequireNoError(t, err)
+
+			RequireEqual(t, string(dec.Data), string(orig.Data))
+		}
+	})
+}
+
 func runValueSubtestsSQL[F EncryptedValueFactory[T], T any](t *testing.T, name string) {
 	t.Run(name, func(t *testing.T) {
 		for _, text := range texts {
 			orig := F(text)
 
-			// For some reason Go does not understand at compile time that orig is a driver.Valuer.
-			// Looks like a bug in the type system. Need to do runtime type assertion here.
+			// The constraint of F has no methods, so the compiler doesn't know that F implements driver.Valuer,
+			// even though every EncryptedValueFactory does (see the assertions in value.go). Hence the runtime assertion.
 			enc, err := any(orig).(driver.Valuer).Value()
 			RequireNoError(t, err)
 
@@ -58,6 +112,98 @@ func runValueSubtestsSQL[F EncryptedValueFactory[T], T any](t *testing.T, name s
 	})
 }
 
+// checkRoundTrip verifies that data survives encryption, as well as the JSON, text and SQL round trips, unchanged.
+// It uses bytes.Equal instead of RequireEqual, which is too slow for the large inputs the fuzzer generates.
+func checkRoundTrip[F EncryptedValueFactory[T], T any](t *testing.T, data []byte) {
+	t.Helper()
+
+	crypter, err := getCrypterFor[T]()
+	RequireNoError(t, err)
+
+	encData, err := crypter.Encrypt(data)
+	RequireNoError(t, err)
+	decData, err := crypter.Decrypt(encData)
+	RequireNoError(t, err)
+	RequireTrue(t, bytes.Equal(decData, data))
+
+	orig := F(data)
+
+	enc, err := json.Marshal(orig)
+	RequireNoError(t, err)
+	var dec F
+	RequireNoError(t, json.Unmarshal(enc, &dec))
+	RequireTrue(t, bytes.Equal(dec, orig))
+
+	enc, err = any(orig).(encoding.TextMarshaler).MarshalText()
+	RequireNoError(t, err)
+	dec = nil
+	RequireNoError(t, any(&dec).(encoding.TextUnmarshaler).UnmarshalText(enc))
+	RequireTrue(t, bytes.Equal(dec, orig))
+
+	v, err := any(orig).(driver.Valuer).Value()
+	RequireNoError(t, err)
+	dec = nil
+	RequireNoError(t, any(&dec).(sql.Scanner).Scan(v))
+	RequireTrue(t, bytes.Equal(dec, orig))
+}
+
+func FuzzEncryptedValue(f *testing.F) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(f, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	c1bypass := MultiKeyCrypter{}
+	c1bypass.AddKey(0x1, DecodeBase64(f, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c1bypass.Bypass = true
+
+	type dummy1 struct{}
+	type dummy2 struct{}
+	type dummy3 struct{}
+	type dummy4 struct{}
+	type dummy5 struct{}
+	BindCrypterTo[EncryptedValueFactory[dummy1]](&c1)
+	BindCrypterTo[EncryptedValueFactory[dummy2]](&c1bypass)
+	BindCrypterTo[EncryptedValueFactory[dummy3]](NoOpCrypter{})
+	BindCrypterTo[EncryptedValueFactory[dummy4]](NoOpCrypter{}, WithTextEncoding())
+	BindCrypterTo[EncryptedValueFactory[dummy5]](&c1, WithPepper([]byte("pepper")))
+	f.Cleanup(func() {
+		UnbindCrypter[EncryptedValueFactory[dummy1]]()
+		UnbindCrypter[EncryptedValueFactory[dummy2]]()
+		UnbindCrypter[EncryptedValueFactory[dummy3]]()
+		UnbindCrypter[EncryptedValueFactory[dummy4]]()
+		UnbindCrypter[EncryptedValueFactory[dummy5]]()
+	})
+
+	for _, text := range texts {
+		f.Add([]byte(text))
+	}
+
+	// NUL bytes, invalid UTF-8, and data that looks like the '#' prefixed JSON form or bypassed data
+	f.Add([]byte{0})
+	f.Add([]byte("\x00\xff\xfe"))
+	f.Add([]byte("\xff#"))
+	f.Add([]byte("#"))
+	f.Add([]byte("##Hello"))
+	f.Add([]byte("\u2028\""))
+	f.Add([]byte("null"))
+	f.Add([]byte(`""`))
+
+	// random payloads, so plain go test runs check more than the fixed inputs above
+	for _, size := range []int{1, 2, 15, 16, 17, 255, 1024} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		RequireNoError(f, err)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkRoundTrip[EncryptedValueFactory[dummy1]](t, data)
+		checkRoundTrip[EncryptedValueFactory[dummy2]](t, data)
+		checkRoundTrip[EncryptedValueFactory[dummy3]](t, data)
+		checkRoundTrip[EncryptedValueFactory[dummy4]](t, data)
+		checkRoundTrip[EncryptedValueFactory[dummy5]](t, data)
+	})
+}
+
 func TestEncryptedValue(t *testing.T) {
 	c1 := MultiKeyCrypter{}
 	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
@@ -74,12 +220,138 @@ func TestEncryptedValue(t *testing.T) {
 	type EncryptedValue2 = EncryptedValueFactory[dummy2]
 	BindCrypterTo[EncryptedValue2](&c2)
 
+	type dummy3 struct{}
+	type EncryptedValue3 = EncryptedValueFactory[dummy3]
+	BindCrypterTo[EncryptedValue3](&c1, WithPepper([]byte("pepper")))
+
+	type dummy4 struct{}
+	type EncryptedValue4 = EncryptedValueFactory[dummy4]
+	BindCrypterTo[EncryptedValue4](&c1, WithPepper([]byte("another pepper")))
+
+	type dummy5 struct{}
+	type EncryptedValue5 = EncryptedValueFactory[dummy5]
+	BindCrypterTo[EncryptedValue5](&c1, WithRejectBypass())
+
+	t.Run("bind twice", func(t *testing.T) {
+		type dummy struct{}
+		type EncryptedValue = EncryptedValueFactory[dummy]
+
+		RequireNoError(t, BindCrypterToErr[EncryptedValue](&c1))
+		RequireErrorIs(t, BindCrypterToErr[EncryptedValue](&c2), ErrCrypterAlreadyBound)
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		BindCrypterTo[EncryptedValue](&c2)
+	})
+
+	t.Run("unbind", func(t *testing.T) {
+		type dummy struct{}
+		type EncryptedValue = EncryptedValueFactory[dummy]
+
+		RequireTrue(t, !UnbindCrypter[EncryptedValue]())
+
+		BindCrypterTo[EncryptedValue](&c2)
+		RequireTrue(t, UnbindCrypter[EncryptedValue]())
+		RequireTrue(t, !UnbindCrypter[EncryptedValue]())
+
+		func() {
+			defer func() {
+				RequireTrue(t, recover() != nil)
+			}()
+			getCrypterFor[dummy]()
+		}()
+
+		// other bindings must not be affected
+		runValueSubtestsSQL[EncryptedValue1](t, "SQL MultiKeyCrypter after unbind")
+
+		// the type can be bound again
+		BindCrypterTo[EncryptedValue](&c1)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+		enc, err := EncryptedValue("Hello, world!").Value()
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.HasPrefix(enc.([]byte), []byte("#")))
+	})
+
+	t.Run("concurrent bind", func(t *testing.T) {
+		type dummy struct{}
+		type EncryptedValue = EncryptedValueFactory[dummy]
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				RequireNoError(t, BindCrypterToErr[EncryptedValue](&c1))
+				RequireTrue(t, UnbindCrypter[EncryptedValue]())
+			}
+		}()
+
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					_, err := json.Marshal(EncryptedValue1("Hello, world!"))
+					RequireNoError(t, err)
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+
 	t.Run("encode/decode", func(t *testing.T) {
 		runValueSubtestsJSON[EncryptedValue1](t, "JSON MultiKeyCrypter")
 		runValueSubtestsJSON[EncryptedValue2](t, "JSON MultiKeyCrypter bypass")
+		runValueSubtestsJSON[EncryptedValue3](t, "JSON MultiKeyCrypter pepper")
+		runValueSubtestsJSON[EncryptedValue5](t, "JSON MultiKeyCrypter reject bypass")
+
+		runValueSubtestsText[EncryptedValue1](t, "Text MultiKeyCrypter")
+		runValueSubtestsText[EncryptedValue2](t, "Text MultiKeyCrypter bypass")
+		runValueSubtestsText[EncryptedValue3](t, "Text MultiKeyCrypter pepper")
+
+		runValueSubtestsSQL[EncryptedValue1](t, "SQL MultiKeyCrypter")
+		runValueSubtestsSQL[EncryptedValue2](t, "SQL MultiKeyCrypter bypass")
+		runValueSubtestsSQL[EncryptedValue3](t, "SQL MultiKeyCrypter pepper")
+		runValueSubtestsSQL[EncryptedValue5](t, "SQL MultiKeyCrypter reject bypass")
+	})
+
+	t.Run("large registry", func(t *testing.T) {
+		// force the map index, normally used only for registries larger than crypterMapThreshold
+		cryptersMu.Lock()
+		crypters.Store(newCrypterRegistry(loadCrypters(), true))
+		cryptersMu.Unlock()
+		t.Cleanup(func() {
+			cryptersMu.Lock()
+			storeCrypters(loadCrypters())
+			cryptersMu.Unlock()
+		})
 
 		runValueSubtestsSQL[EncryptedValue1](t, "SQL MultiKeyCrypter")
 		runValueSubtestsSQL[EncryptedValue2](t, "SQL MultiKeyCrypter bypass")
+		runValueSubtestsSQL[EncryptedValue3](t, "SQL MultiKeyCrypter pepper")
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		type dummy struct{}
+		getCrypterFor[dummy]()
+	})
+
+	t.Run("pepper mismatch", func(t *testing.T) {
+		enc, err := EncryptedValue3("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		var dec1 EncryptedValue1
+		err = dec1.Scan(enc)
+		RequireNoError(t, err)
+		RequireEqual(t, dec1, EncryptedValue1("pepperHello, world!"))
+
+		var dec4 EncryptedValue4
+		err = dec4.Scan(enc)
+		RequireEqual(t, err, ErrPepperMismatch)
 	})
 
 	t.Run("JSON encrypt", func(t *testing.T) {
@@ -99,6 +371,145 @@ func TestEncryptedValue(t *testing.T) {
 		enc, err := json.Marshal(orig)
 		RequireNoError(t, err)
 		RequireEqual(t, string(enc), `"##Hello, world!"`)
+
+		// one '#' for the text encoding, one for bypass mode, and the rest is the value itself
+		for _, text := range []string{"#foo", "##foo"} {
+			enc, err := json.Marshal(EncryptedValue2(text))
+			RequireNoError(t, err)
+			RequireEqual(t, string(enc), `"##`+text+`"`)
+
+			var dec EncryptedValue2
+			RequireNoError(t, json.Unmarshal(enc, &dec))
+			RequireEqual(t, string(dec), text)
+
+			var decEncrypted EncryptedValue1
+			RequireNoError(t, json.Unmarshal(enc, &decEncrypted))
+			RequireEqual(t, string(decEncrypted), text)
+		}
+	})
+
+	t.Run("JSON format discriminator", func(t *testing.T) {
+		type dummy struct{}
+		type EncryptedValue = EncryptedValueFactory[dummy]
+		BindCrypterTo[EncryptedValue](NoOpCrypter{})
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+		// binary ciphertext is always base64-encoded, and base64 never starts with '#'
+		for i := 0; i < 100; i++ {
+			enc, err := json.Marshal(EncryptedValue1(fmt.Sprintf("value %d", i)))
+			RequireNoError(t, err)
+			RequireTrue(t, enc[1] != '#')
+
+			var text string
+			RequireNoError(t, json.Unmarshal(enc, &text))
+			_, err = base64.StdEncoding.DecodeString(text)
+			RequireNoError(t, err)
+		}
+
+		for _, tc := range []struct {
+			text string
+			json string
+		}{
+			{"#", `"##"`},
+			{"SGVsbG8=", `"#SGVsbG8="`}, // valid base64, but must not be decoded
+			{"\xff", `"/w=="`},
+			{"\x00#", `"#\u0000#"`},
+		} {
+			enc, err := json.Marshal(EncryptedValue(tc.text))
+			RequireNoError(t, err)
+			RequireEqual(t, string(enc), tc.json)
+
+			var dec EncryptedValue
+			RequireNoError(t, json.Unmarshal(enc, &dec))
+			RequireEqual(t, string(dec), tc.text)
+		}
+
+		// the discriminator is the first character of the decoded string, not of the raw JSON
+		var dec EncryptedValue
+		RequireNoError(t, json.Unmarshal([]byte(`"\u0023SGVsbG8="`), &dec))
+		RequireEqual(t, string(dec), "SGVsbG8=")
+
+		RequireError(t, json.Unmarshal([]byte(`"Hello!"`), &dec))
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		type user struct {
+			Username string          `yaml:"username"`
+			Token    EncryptedValue1 `yaml:"token"`
+			Note     EncryptedValue2 `yaml:"note"`
+		}
+
+		orig := user{Username: "john", Token: EncryptedValue1("some token"), Note: EncryptedValue2("some note")}
+
+		enc, err := yaml.Marshal(orig)
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Contains(enc, []byte("some token")))
+		RequireTrue(t, bytes.Contains(enc, []byte("##some note")))
+
+		var dec user
+		RequireNoError(t, yaml.Unmarshal(enc, &dec))
+		RequireEqual(t, dec.Username, orig.Username)
+		RequireEqual(t, dec.Token, orig.Token)
+		RequireEqual(t, dec.Note, orig.Note)
+	})
+
+	t.Run("String redacted", func(t *testing.T) {
+		v := EncryptedValue1("Hello, world!")
+		RequireEqual(t, v.String(), "EncryptedValue(<redacted 13 bytes>)")
+		for _, format := range []string{"%v", "%+v", "%s", "%#v", "%d", "%x", "%X", "%q", "%10v"} {
+			RequireEqual(t, fmt.Sprintf(format, v), "EncryptedValue(<redacted 13 bytes>)")
+		}
+
+		type user struct {
+			Token   EncryptedValue1
+			Profile EncryptedJSONFactory[string, dummy1]
+			Shared  EncryptedJSON[map[string]string]
+		}
+
+		u := user{
+			Token:   v,
+			Profile: EncryptedJSONFactory[string, dummy1]{Data: "Hello, world!"},
+			Shared:  EncryptedJSON[map[string]string]{Data: map[string]string{"greeting": "Hello, world!"}},
+		}
+
+		RequireEqual(t, u.Profile.String(), "EncryptedJSON(<redacted>)")
+		RequireEqual(t, fmt.Sprintf("%#v", u.Shared), "EncryptedJSON(<redacted>)")
+
+		for _, format := range []string{"%v", "%+v", "%s", "%#v", "%d", "%q"} {
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, u), "Hello"))
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, u.Profile), "Hello"))
+			RequireTrue(t, !strings.Contains(fmt.Sprintf(format, u.Shared), "greeting"))
+		}
+	})
+
+	t.Run("Reveal", func(t *testing.T) {
+		v := EncryptedValue1("Hello, world!")
+		RequireEqual(t, v.Reveal(), []byte("Hello, world!"))
+		RequireEqual(t, v.RevealString(), "Hello, world!")
+
+		// no copy is made
+		RequireTrue(t, &v.Reveal()[0] == &v[0])
+
+		RequireEqual(t, len(EncryptedValue1(nil).Reveal()), 0)
+		RequireEqual(t, EncryptedValue1(nil).RevealString(), "")
+	})
+
+	t.Run("Zero", func(t *testing.T) {
+		enc, err := EncryptedValue1("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		var v EncryptedValue1
+		RequireNoError(t, v.Scan(enc))
+
+		plaintext := v.Reveal()
+		v.Zero()
+
+		RequireTrue(t, v == nil)
+		RequireEqual(t, plaintext, make([]byte, 13))
+
+		// no-op for empty values
+		v.Zero()
+		RequireTrue(t, v == nil)
 	})
 
 	t.Run("SQL scan string", func(t *testing.T) {
@@ -111,6 +522,112 @@ func TestEncryptedValue(t *testing.T) {
 		RequireEqual(t, dec, EncryptedValue1("Hello, world!"))
 	})
 
+	t.Run("SQL scan RawBytes", func(t *testing.T) {
+		enc, err := EncryptedValue1("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		raw := sql.RawBytes(bytes.Clone(enc.([]byte)))
+
+		var dec EncryptedValue1
+		err = dec.Scan(raw)
+		RequireNoError(t, err)
+		RequireEqual(t, dec, EncryptedValue1("Hello, world!"))
+
+		// the driver reuses the buffer on the next scan
+		clear(raw)
+		RequireEqual(t, dec, EncryptedValue1("Hello, world!"))
+
+		// bypass data is copied too
+		raw = sql.RawBytes("#Hello, world!")
+		err = dec.Scan(raw)
+		RequireNoError(t, err)
+		copy(raw, "#XXXXXXXXXXXXX")
+		RequireEqual(t, dec, EncryptedValue1("Hello, world!"))
+	})
+
+	t.Run("SQL scan with NoOpCrypter", func(t *testing.T) {
+		type dummy struct{}
+		type EncryptedValue = EncryptedValueFactory[dummy]
+		BindCrypterTo[EncryptedValue](NoOpCrypter{})
+		t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+		buf := []byte("Hello, world!")
+
+		var dec EncryptedValue
+		err := dec.Scan(buf)
+		RequireNoError(t, err)
+
+		// the driver reuses the buffer on the next scan
+		copy(buf, "XXXXXXXXXXXXX")
+		RequireEqual(t, dec, EncryptedValue("Hello, world!"))
+	})
+
+	t.Run("SQL scan named types", func(t *testing.T) {
+		type myString string
+		type myBytes []byte
+
+		var dec EncryptedValue1
+		err := dec.Scan(myString("#Hello, world!"))
+		RequireNoError(t, err)
+		RequireEqual(t, dec, EncryptedValue1("Hello, world!"))
+
+		err = dec.Scan(myBytes("#Hello, again!"))
+		RequireNoError(t, err)
+		RequireEqual(t, dec, EncryptedValue1("Hello, again!"))
+
+		err = dec.Scan(myString(""))
+		RequireNoError(t, err)
+		RequireEqual(t, dec, EncryptedValue1(""))
+
+		err = dec.Scan(42)
+		RequireError(t, err)
+	})
+
+	t.Run("SQL scan bypass rejected", func(t *testing.T) {
+		enc := driver.Value("#Hello, world!")
+
+		var dec EncryptedValue5
+		err := dec.Scan(enc)
+		RequireErrorIs(t, err, ErrBypassNotAllowed)
+
+		var decJSON EncryptedValue5
+		err = json.Unmarshal([]byte(`"##Hello, world!"`), &decJSON)
+		RequireErrorIs(t, err, ErrBypassNotAllowed)
+	})
+
+	t.Run("bypass rejected through wrappers", func(t *testing.T) {
+		checkRejected := func(t *testing.T, dec interface{ Scan(any) error }) {
+			t.Helper()
+			RequireErrorIs(t, dec.Scan(driver.Value("#Hello, world!")), ErrBypassNotAllowed)
+		}
+
+		type dummyCache struct{}
+		BindCrypterTo[EncryptedValueFactory[dummyCache]](NewCachingCrypter(&c1, 10, time.Minute), WithRejectBypass())
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummyCache]]() })
+		checkRejected(t, new(EncryptedValueFactory[dummyCache]))
+
+		type dummyChain struct{}
+		BindCrypterTo[EncryptedValueFactory[dummyChain]](NewChainCrypter(NoOpCrypter{}, &c1), WithRejectBypass())
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummyChain]]() })
+		checkRejected(t, new(EncryptedValueFactory[dummyChain]))
+
+		type dummyPadding struct{}
+		BindCrypterTo[EncryptedValueFactory[dummyPadding]](NewPaddingCrypter(&c1, PadToMultiple(16)), WithRejectBypass())
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummyPadding]]() })
+		checkRejected(t, new(EncryptedValueFactory[dummyPadding]))
+
+		type dummyCompress struct{}
+		BindCrypterToType(dummyCompress{}, NewCompressingCrypter(NewCachingCrypter(&c1, 10, 0)), WithRejectBypass())
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummyCompress]]() })
+		checkRejected(t, new(EncryptedValueFactory[dummyCompress]))
+
+		// crypters that can't detect bypass data can't enforce the option
+		type dummyNoOp struct{}
+		RequireErrorIs(t, BindCrypterToErr[EncryptedValueFactory[dummyNoOp]](NoOpCrypter{}, WithRejectBypass()), ErrBypassUnsupported)
+		RequireErrorIs(t, BindCrypterToTypeErr(dummyNoOp{}, NoOpCrypter{}, WithRejectBypass()), ErrBypassUnsupported)
+		RequireTrue(t, !UnbindCrypter[EncryptedValueFactory[dummyNoOp]]())
+	})
+
 	t.Run("SQL scan nil", func(t *testing.T) {
 		enc := driver.Value(nil)
 
@@ -121,3 +638,550 @@ func TestEncryptedValue(t *testing.T) {
 		RequireEqual(t, dec, EncryptedValue1(""))
 	})
 }
+
+func TestMissingCrypter(t *testing.T) {
+	c := MultiKeyCrypter{}
+	c.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type dummy2 struct{}
+	type EncryptedValue2 = EncryptedValueFactory[dummy2] // not bound
+
+	requirePanic := func(t *testing.T, f func()) {
+		t.Helper()
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		f()
+	}
+
+	t.Run("panic policy", func(t *testing.T) {
+		requirePanic(t, func() { _, _ = EncryptedValue2("Hello, world!").Value() })
+		requirePanic(t, func() { _, _ = json.Marshal(EncryptedValue2("Hello, world!")) })
+
+		var dec EncryptedValue2
+		requirePanic(t, func() { _ = dec.Scan([]byte("#Hello, world!")) })
+
+		// empty values don't need a crypter
+		v, err := EncryptedValue2("").Value()
+		RequireNoError(t, err)
+		RequireEqual(t, v, driver.Value([]byte{}))
+	})
+
+	t.Run("error policy", func(t *testing.T) {
+		SetMissingCrypterPolicy(PolicyError)
+		t.Cleanup(func() { SetMissingCrypterPolicy(PolicyPanic) })
+
+		_, err := EncryptedValue2("Hello, world!").Value()
+		RequireErrorIs(t, err, ErrNoCrypter)
+
+		_, err = json.Marshal(EncryptedValue2("Hello, world!"))
+		RequireErrorIs(t, err, ErrNoCrypter)
+
+		_, err = WithAAD(EncryptedValue2("Hello, world!"), []byte("aad")).Value()
+		RequireErrorIs(t, err, ErrNoCrypter)
+
+		var dec EncryptedValue2
+		RequireErrorIs(t, dec.Scan([]byte("#Hello, world!")), ErrNoCrypter)
+		RequireErrorIs(t, json.Unmarshal([]byte(`"#Hello, world!"`), &dec), ErrNoCrypter)
+
+		// bound types are not affected
+		runValueSubtestsSQL[EncryptedValue1](t, "SQL MultiKeyCrypter")
+
+		// the policy survives registry changes
+		type dummy3 struct{}
+		BindCrypterTo[EncryptedValueFactory[dummy3]](&c)
+		UnbindCrypter[EncryptedValueFactory[dummy3]]()
+
+		_, err = EncryptedValue2("Hello, world!").Value()
+		RequireErrorIs(t, err, ErrNoCrypter)
+	})
+
+	t.Run("default crypter", func(t *testing.T) {
+		SetDefaultCrypter(&c)
+		t.Cleanup(func() { SetDefaultCrypter(nil) })
+
+		runValueSubtestsSQL[EncryptedValue2](t, "SQL default crypter")
+		runValueSubtestsJSON[EncryptedValue2](t, "JSON default crypter")
+
+		// compatible with the bound type, since the crypter is the same
+		enc, err := EncryptedValue2("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedValue1
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, string(dec), "Hello, world!")
+
+		// removing the default crypter restores the policy
+		SetDefaultCrypter(nil)
+		requirePanic(t, func() { _, _ = EncryptedValue2("Hello, world!").Value() })
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		requirePanic(t, func() { SetMissingCrypterPolicy(MissingCrypterPolicy(42)) })
+	})
+}
+
+type runtimeDummy1 struct{}
+type runtimeDummy2 struct{}
+
+func TestBindCrypterToType(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	c2 := MultiKeyCrypter{}
+	c2.AddKey(0x1, DecodeBase64(t, "D4xyo0odW5doB3rlLQ+2XglIqXJdq4QSOFFs/fqAAEU="))
+
+	type EncryptedValue1 = EncryptedValueFactory[runtimeDummy1]
+	type EncryptedValue2 = EncryptedValueFactory[runtimeDummy2]
+
+	// discovered at runtime
+	for _, zero := range []any{runtimeDummy1{}, runtimeDummy2{}} {
+		BindCrypterToType(zero, &c1, WithPepper([]byte("pepper")))
+	}
+	t.Cleanup(func() {
+		UnbindCrypter[EncryptedValue1]()
+		UnbindCrypter[EncryptedValue2]()
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		m := crypters.Load().lookup(reflect.TypeOf(runtimeDummy1{}))
+		RequireTrue(t, m != nil)
+		RequireTrue(t, m.Crypter == &c1)
+		RequireEqual(t, string(m.Pepper), "pepper")
+
+		// same mapping as the generic lookup
+		m2, err := getCrypterFor[runtimeDummy1]()
+		RequireNoError(t, err)
+		RequireTrue(t, m == m2)
+
+		// the generic bindings are visible as well
+		type dummy3 struct{}
+		BindCrypterTo[EncryptedValueFactory[dummy3]](&c2)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummy3]]() })
+
+		m = crypters.Load().lookup(reflect.TypeOf(dummy3{}))
+		RequireTrue(t, m != nil)
+		RequireTrue(t, m.Crypter == &c2)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		enc, err := EncryptedValue1("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedValue1
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, string(dec), "Hello, world!")
+
+		// options are applied
+		raw, err := c1.Decrypt(enc.([]byte))
+		RequireNoError(t, err)
+		RequireTrue(t, bytes.HasPrefix(raw, []byte("pepper")))
+	})
+
+	t.Run("already bound", func(t *testing.T) {
+		RequireErrorIs(t, BindCrypterToTypeErr(runtimeDummy1{}, &c2), ErrCrypterAlreadyBound)
+		RequireErrorIs(t, BindCrypterToErr[EncryptedValue1](&c2), ErrCrypterAlreadyBound)
+
+		type dummy4 struct{}
+		BindCrypterTo[EncryptedValueFactory[dummy4]](&c2)
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummy4]]() })
+		RequireErrorIs(t, BindCrypterToTypeErr(dummy4{}, &c1), ErrCrypterAlreadyBound)
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		BindCrypterToType(nil, &c1)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		type dummy5 struct{}
+
+		SetMissingCrypterPolicy(PolicyError)
+		t.Cleanup(func() { SetMissingCrypterPolicy(PolicyPanic) })
+
+		RequireTrue(t, crypters.Load().lookup(reflect.TypeOf(dummy5{})) == nil)
+
+		_, err := EncryptedValueFactory[dummy5]("Hello, world!").Value()
+		RequireErrorIs(t, err, ErrNoCrypter)
+	})
+}
+
+func TestBindCrypterToAll(t *testing.T) {
+	c := MultiKeyCrypter{}
+	c.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type dummy2 struct{}
+	type dummy3 struct{}
+	BindCrypterToAll(&c, dummy1{}, dummy2{}, dummy3{})
+	t.Cleanup(func() {
+		UnbindCrypter[EncryptedValueFactory[dummy1]]()
+		UnbindCrypter[EncryptedValueFactory[dummy2]]()
+		UnbindCrypter[EncryptedValueFactory[dummy3]]()
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		for _, zero := range []any{dummy1{}, dummy2{}, dummy3{}} {
+			m := crypters.Load().lookup(reflect.TypeOf(zero))
+			RequireTrue(t, m != nil)
+			RequireTrue(t, m.Crypter == &c)
+		}
+
+		m, err := getCrypterFor[dummy2]()
+		RequireNoError(t, err)
+		RequireTrue(t, m.Crypter == &c)
+
+		enc, err := EncryptedValueFactory[dummy3]("Hello, world!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedValueFactory[dummy1]
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, string(dec), "Hello, world!")
+	})
+
+	t.Run("already bound", func(t *testing.T) {
+		type dummy4 struct{}
+		type dummy5 struct{}
+
+		// nothing is bound if any of the types is already bound
+		RequireErrorIs(t, BindCrypterToAllErr(&c, dummy4{}, dummy2{}), ErrCrypterAlreadyBound)
+		RequireTrue(t, !UnbindCrypter[EncryptedValueFactory[dummy4]]())
+
+		// including duplicates within the call
+		RequireErrorIs(t, BindCrypterToAllErr(&c, dummy5{}, dummy5{}), ErrCrypterAlreadyBound)
+		RequireTrue(t, !UnbindCrypter[EncryptedValueFactory[dummy5]]())
+
+		RequireErrorIs(t, BindCrypterToErr[EncryptedValueFactory[dummy1]](&c), ErrCrypterAlreadyBound)
+
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		BindCrypterToAll(&c, dummy4{}, nil)
+	})
+}
+
+func TestTextEncoding(t *testing.T) {
+	c := MultiKeyCrypter{}
+	c.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy struct{}
+	type EncryptedValue = EncryptedValueFactory[dummy]
+	BindCrypterTo[EncryptedValue](&c, WithTextEncoding())
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+	db, err := sql.Open("ramsql", "TestTextEncoding")
+	RequireNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// no primary key: ramsql index lookups fail checkptr validation under -race
+	_, err = db.Exec("CREATE TABLE users (id INT, token TEXT)")
+	RequireNoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		v, err := EncryptedValue("Hello, world!").Value()
+		RequireNoError(t, err)
+		_, ok := v.(string)
+		RequireTrue(t, ok)
+
+		_, err = db.Exec("INSERT INTO users (id, token) VALUES (?, ?)", 1, EncryptedValue("Hello, world!"))
+		RequireNoError(t, err)
+
+		var raw string
+		RequireNoError(t, db.QueryRow("SELECT token FROM users WHERE id = 1").Scan(&raw))
+		RequireTrue(t, !strings.Contains(raw, "Hello"))
+		_, err = base64.StdEncoding.DecodeString(raw)
+		RequireNoError(t, err)
+
+		var dec EncryptedValue
+		RequireNoError(t, db.QueryRow("SELECT token FROM users WHERE id = 1").Scan(&dec))
+		RequireEqual(t, string(dec), "Hello, world!")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		v, err := EncryptedValue("").Value()
+		RequireNoError(t, err)
+		RequireEqual(t, v, driver.Value(""))
+
+		var dec EncryptedValue
+		RequireNoError(t, dec.Scan(""))
+		RequireTrue(t, dec == nil)
+	})
+
+	t.Run("derived types", func(t *testing.T) {
+		type EncryptedString = EncryptedStringFactory[dummy]
+		type EncryptedTime = EncryptedTimeFactory[dummy]
+		type NullEncryptedValue = NullEncryptedValueFactory[dummy]
+
+		values := []driver.Valuer{
+			EncryptedString("Hello, world!"),
+			EncryptedTime{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			NullEncryptedValue{EncryptedValue: EncryptedValue("Hello, world!"), Valid: true},
+			EncryptedJSONFactory[map[string]int, dummy]{Data: map[string]int{"a": 1}},
+		}
+		scanners := []sql.Scanner{
+			new(EncryptedString),
+			new(EncryptedTime),
+			new(NullEncryptedValue),
+			new(EncryptedJSONFactory[map[string]int, dummy]),
+		}
+
+		for i, orig := range values {
+			v, err := orig.Value()
+			RequireNoError(t, err)
+			_, ok := v.(string)
+			RequireTrue(t, ok)
+
+			RequireNoError(t, scanners[i].Scan(v))
+			RequireTrue(t, reflect.DeepEqual(reflect.ValueOf(scanners[i]).Elem().Interface(), orig))
+		}
+	})
+
+	t.Run("binary data", func(t *testing.T) {
+		enc, err := c.Encrypt([]byte("Hello, world!"))
+		RequireNoError(t, err)
+
+		var dec EncryptedValue
+		RequireError(t, dec.Scan(enc))
+	})
+}
+
+func TestJSONEncoding(t *testing.T) {
+	c := MultiKeyCrypter{}
+	c.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	type dummy1 struct{}
+	type dummy2 struct{}
+	type dummy3 struct{}
+	BindCrypterTo[EncryptedValueFactory[dummy1]](&c, WithJSONEncoding(JSONBase64))
+	BindCrypterTo[EncryptedValueFactory[dummy2]](&c, WithJSONEncoding(JSONBase64URL))
+	BindCrypterTo[EncryptedValueFactory[dummy3]](&c, WithJSONEncoding(JSONHex))
+	t.Cleanup(func() {
+		UnbindCrypter[EncryptedValueFactory[dummy1]]()
+		UnbindCrypter[EncryptedValueFactory[dummy2]]()
+		UnbindCrypter[EncryptedValueFactory[dummy3]]()
+	})
+
+	runValueSubtestsJSON[EncryptedValueFactory[dummy1]](t, "base64")
+	runValueSubtestsJSON[EncryptedValueFactory[dummy2]](t, "base64 url")
+	runValueSubtestsJSON[EncryptedValueFactory[dummy3]](t, "hex")
+	runValueSubtestsText[EncryptedValueFactory[dummy3]](t, "hex text")
+	runValueSubtestsXML[EncryptedValueFactory[dummy1]](t, "base64 XML")
+	runValueSubtestsXML[EncryptedValueFactory[dummy2]](t, "base64 url XML")
+	runValueSubtestsXML[EncryptedValueFactory[dummy3]](t, "hex XML")
+
+	// checks that the JSON string consists only of the given characters
+	requireAlphabet := func(t *testing.T, enc []byte, alphabet string) {
+		t.Helper()
+
+		var text string
+		RequireNoError(t, json.Unmarshal(enc, &text))
+		RequireTrue(t, len(text) > 0)
+		for _, r := range text {
+			if !strings.ContainsRune(alphabet, r) {
+				t.Fatalf("unexpected character %q in %s", r, text)
+			}
+		}
+	}
+
+	const alnum = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+	t.Run("alphabets", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			text := fmt.Sprintf("value %d", i)
+
+			enc, err := json.Marshal(EncryptedValueFactory[dummy1](text))
+			RequireNoError(t, err)
+			requireAlphabet(t, enc, alnum+"+/=")
+
+			enc, err = json.Marshal(EncryptedValueFactory[dummy2](text))
+			RequireNoError(t, err)
+			requireAlphabet(t, enc, alnum+"-_")
+
+			enc, err = json.Marshal(EncryptedValueFactory[dummy3](text))
+			RequireNoError(t, err)
+			requireAlphabet(t, enc, "0123456789abcdef")
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		enc, err := json.Marshal(EncryptedValueFactory[dummy1]("Hello, world!"))
+		RequireNoError(t, err)
+
+		var dec EncryptedValueFactory[dummy3]
+		RequireError(t, json.Unmarshal(enc, &dec))
+	})
+
+	t.Run("utf8 data", func(t *testing.T) {
+		cBypass := MultiKeyCrypter{}
+		cBypass.AddKey(1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+		cBypass.Bypass = true
+
+		type dummy4 struct{}
+		BindCrypterTo[EncryptedValueFactory[dummy4]](&cBypass, WithJSONEncoding(JSONHex))
+		t.Cleanup(func() { UnbindCrypter[EncryptedValueFactory[dummy4]]() })
+
+		enc, err := json.Marshal(EncryptedValueFactory[dummy4]("Hello, world!"))
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), `"##Hello, world!"`)
+	})
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		defer func() {
+			RequireTrue(t, recover() != nil)
+		}()
+		WithJSONEncoding(JSONEncoding(42))
+	})
+}
+
+func BenchmarkEncryptedValue(b *testing.B) {
+	c := MultiKeyCrypter{}
+	c.AddKey(0x1, make([]byte, 32))
+
+	type dummy struct{}
+	type EncryptedValue = EncryptedValueFactory[dummy]
+	BindCrypterTo[EncryptedValue](&c)
+	b.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+	for _, size := range []int{16, 256, 4 * 1024} {
+		v := EncryptedValue(bytes.Repeat([]byte("a"), size))
+
+		b.Run(fmt.Sprintf("JSON/%dB", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			for i := 0; i < b.N; i++ {
+				enc, err := json.Marshal(v)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var dec EncryptedValue
+				if err := json.Unmarshal(enc, &dec); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("SQL/%dB", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+
+			for i := 0; i < b.N; i++ {
+				enc, err := v.Value()
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var dec EncryptedValue
+				if err := dec.Scan(enc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetCrypterFor(b *testing.B) {
+	type target struct{}
+
+	for _, n := range []int{1, 4, 16, 64, 128} {
+		// n-1 unrelated mappings, and the target in the middle
+		list := make([]crypterMapping, 0, n)
+		for i := 0; i < n-1; i++ {
+			typ := reflect.ArrayOf(i+1, reflect.TypeOf(byte(0)))
+			list = append(list, crypterMapping{Zero: reflect.Zero(typ).Interface(), Type: typ})
+		}
+		list = append(list[:n/2], append([]crypterMapping{{Zero: target{}, Type: reflect.TypeOf(target{})}}, list[n/2:]...)...)
+
+		for _, withIndex := range []bool{false, true} {
+			name := "scan"
+			if withIndex {
+				name = "map"
+			}
+
+			b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+				cryptersMu.Lock()
+				saved := crypters.Load()
+				crypters.Store(newCrypterRegistry(list, withIndex))
+				cryptersMu.Unlock()
+
+				defer func() {
+					cryptersMu.Lock()
+					crypters.Store(saved)
+					cryptersMu.Unlock()
+				}()
+
+				for i := 0; i < b.N; i++ {
+					getCrypterFor[target]()
+				}
+			})
+		}
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	BindCrypterTo[EncryptedValue](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue]() })
+
+	t.Run("EncryptedBytes", func(t *testing.T) {
+		enc, err := EncryptedBytes("Hello, World!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedBytes
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.RevealString(), "Hello, World!")
+
+		// interchangeable with EncryptedValue
+		var v EncryptedValue = dec
+		RequireEqual(t, v.RevealString(), "Hello, World!")
+
+		js, err := json.Marshal(dec)
+		RequireNoError(t, err)
+		dec = nil
+		RequireNoError(t, json.Unmarshal(js, &dec))
+		RequireEqual(t, dec.RevealString(), "Hello, World!")
+	})
+
+	t.Run("EncryptedString", func(t *testing.T) {
+		enc, err := EncryptedString("Hello, World!").Value()
+		RequireNoError(t, err)
+
+		var dec EncryptedString
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Reveal(), "Hello, World!")
+
+		// same encrypted representation as EncryptedValue
+		var v EncryptedValue
+		RequireNoError(t, v.Scan(enc))
+		RequireEqual(t, v.RevealString(), "Hello, World!")
+	})
+
+	t.Run("EncryptedJSON", func(t *testing.T) {
+		type profile struct {
+			Card string
+		}
+
+		enc, err := EncryptedJSON[profile]{Data: profile{Card: "4242"}}.Value()
+		RequireNoError(t, err)
+		RequireTrue(t, !bytes.Contains(enc.([]byte), []byte("4242")))
+
+		var dec EncryptedJSON[profile]
+		RequireNoError(t, dec.Scan(enc))
+		RequireEqual(t, dec.Data.Card, "4242")
+
+		js, err := json.Marshal(dec)
+		RequireNoError(t, err)
+
+		var dec2 EncryptedJSON[profile]
+		RequireNoError(t, json.Unmarshal(js, &dec2))
+		RequireEqual(t, dec2.Data.Card, "4242")
+	})
+}
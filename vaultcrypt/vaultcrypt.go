@@ -0,0 +1,154 @@
+// Package vaultcrypt provides a [silent.Crypter] backed by the transit secrets engine of HashiCorp Vault.
+// It lives in a separate module to keep the Vault client out of the core module dependencies.
+//
+// Keys never leave Vault: data is sent to Vault for encryption and decryption,
+// and the ciphertext returned by Vault (vault:v1:...) is stored as is.
+// Key rotation is handled by Vault as well: data is always encrypted with the latest version of the key,
+// and decrypted with the version embedded in the ciphertext.
+package vaultcrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/destel/silent"
+)
+
+var errUnexpectedResponse = errors.New("unexpected response from vault")
+
+var _ silent.ContextCrypter = (*Crypter)(nil)
+
+// Crypter encrypts and decrypts data using a named key of the Vault transit engine.
+type Crypter struct {
+	client  *api.Client
+	keyName string
+
+	// MountPath is the path the transit engine is mounted at. If empty, "transit" is used.
+	MountPath string
+}
+
+// New creates a Crypter that uses the key with the given name.
+func New(client *api.Client, keyName string) *Crypter {
+	if client == nil {
+		panic("misconfiguration: client is nil")
+	}
+
+	if keyName == "" {
+		panic("misconfiguration: key name is empty")
+	}
+
+	return &Crypter{
+		client:  client,
+		keyName: keyName,
+	}
+}
+
+// Encrypt is the same as [Crypter.EncryptContext] with the background context.
+func (c *Crypter) Encrypt(data []byte) ([]byte, error) {
+	return c.EncryptContext(context.Background(), data)
+}
+
+// Decrypt is the same as [Crypter.DecryptContext] with the background context.
+func (c *Crypter) Decrypt(data []byte) ([]byte, error) {
+	return c.DecryptContext(context.Background(), data)
+}
+
+// EncryptContext encrypts the data with the latest version of the key.
+func (c *Crypter) EncryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	secret, err := c.client.Logical().WriteWithContext(ctx, c.path("encrypt"), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(data),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := responseField(secret, "ciphertext")
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(ciphertext), nil
+}
+
+// DecryptContext decrypts the data with the version of the key embedded in the data.
+func (c *Crypter) DecryptContext(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if _, err := c.KeyIDOf(data); err != nil {
+		return nil, err
+	}
+
+	secret, err := c.client.Logical().WriteWithContext(ctx, c.path("decrypt"), map[string]interface{}{
+		"ciphertext": string(data),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := responseField(secret, "plaintext")
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+// KeyIDOf returns the version of the key that was used to encrypt the data, without decrypting it.
+// Data that is not a Vault ciphertext results in a [silent.DecryptError].
+func (c *Crypter) KeyIDOf(data []byte) (uint32, error) {
+	if len(data) == 0 {
+		return 0, silent.ErrEmptyData
+	}
+
+	// vault:v<version>:<base64>
+	rest, ok := bytes.CutPrefix(data, []byte("vault:v"))
+	if !ok {
+		return 0, &silent.DecryptError{Kind: silent.KindUnsupportedVersion, Version: data[0], Cause: silent.ErrUnsupportedVersion}
+	}
+
+	version, _, ok := bytes.Cut(rest, []byte(":"))
+	if !ok {
+		return 0, &silent.DecryptError{Kind: silent.KindCorrupt, Version: data[0], Cause: silent.ErrTruncated}
+	}
+
+	res, err := strconv.ParseUint(string(version), 10, 32)
+	if err != nil {
+		return 0, &silent.DecryptError{Kind: silent.KindCorrupt, Version: data[0], Cause: err}
+	}
+
+	return uint32(res), nil
+}
+
+func (c *Crypter) path(op string) string {
+	mount := c.MountPath
+	if mount == "" {
+		mount = "transit"
+	}
+
+	return mount + "/" + op + "/" + c.keyName
+}
+
+func responseField(secret *api.Secret, name string) (string, error) {
+	if secret == nil || secret.Data == nil {
+		return "", errUnexpectedResponse
+	}
+
+	res, ok := secret.Data[name].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: missing %s", errUnexpectedResponse, name)
+	}
+
+	return res, nil
+}
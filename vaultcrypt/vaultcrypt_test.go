@@ -0,0 +1,166 @@
+package vaultcrypt_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/destel/silent"
+	"github.com/destel/silent/vaultcrypt"
+)
+
+// fakeTransit emulates the encrypt and decrypt endpoints of the Vault transit engine.
+// Its "encryption" is a reversible transformation, which is enough to check the wire calls.
+type fakeTransit struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+	f.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+
+	var req map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"errors":["invalid request"]}`, http.StatusBadRequest)
+		return
+	}
+
+	var data map[string]string
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/transit/encrypt/"):
+		data = map[string]string{"ciphertext": "vault:v2:" + reverse(req["plaintext"])}
+	case strings.HasPrefix(r.URL.Path, "/v1/transit/decrypt/"):
+		_, rest, _ := strings.Cut(strings.TrimPrefix(req["ciphertext"], "vault:"), ":")
+		data = map[string]string{"plaintext": reverse(rest)}
+	default:
+		http.Error(w, `{"errors":["not found"]}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func reverse(s string) string {
+	res := []byte(s)
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return string(res)
+}
+
+func newClient(t *testing.T, handler http.Handler) *api.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	cfg.MaxRetries = 0
+
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("test-token")
+	return client
+}
+
+func TestCrypter(t *testing.T) {
+	fake := &fakeTransit{}
+	c := vaultcrypt.New(newClient(t, fake), "users")
+
+	t.Run("encrypt/decrypt", func(t *testing.T) {
+		text := []byte("Hello, World!")
+
+		enc, err := c.Encrypt(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "vault:v2:" + reverse(base64.StdEncoding.EncodeToString(text))
+		if string(enc) != expected {
+			t.Fatalf("expected %q, got %q", expected, enc)
+		}
+
+		keyID, err := c.KeyIDOf(enc)
+		if err != nil || keyID != 2 {
+			t.Fatalf("expected key version 2, got %d, %v", keyID, err)
+		}
+
+		dec, err := c.Decrypt(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dec, text) {
+			t.Fatalf("expected %q, got %q", text, dec)
+		}
+
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if strings.Join(fake.paths, ", ") != "PUT /v1/transit/encrypt/users, PUT /v1/transit/decrypt/users" {
+			t.Fatalf("unexpected calls: %v", fake.paths)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		enc, err := c.Encrypt(nil)
+		if err != nil || len(enc) != 0 {
+			t.Fatalf("expected empty data, got %q, %v", enc, err)
+		}
+
+		dec, err := c.Decrypt(nil)
+		if err != nil || len(dec) != 0 {
+			t.Fatalf("expected empty data, got %q, %v", dec, err)
+		}
+	})
+
+	t.Run("not a vault ciphertext", func(t *testing.T) {
+		if _, err := c.Decrypt([]byte{1, 2, 3}); !errors.Is(err, silent.ErrUnsupportedVersion) {
+			t.Fatalf("expected unsupported version error, got %v", err)
+		}
+
+		if _, err := c.Decrypt([]byte("vault:vX:abc")); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("vault errors", func(t *testing.T) {
+		client := newClient(t, fake)
+		client.SetToken("wrong token")
+		c := vaultcrypt.New(client, "users")
+
+		if _, err := c.Encrypt([]byte("Hello, World!")); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("mount path", func(t *testing.T) {
+		c := vaultcrypt.New(newClient(t, fake), "users")
+		c.MountPath = "custom"
+
+		if _, err := c.Encrypt([]byte("Hello, World!")); err == nil {
+			t.Fatal("expected error")
+		}
+
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if last := fake.paths[len(fake.paths)-1]; last != "PUT /v1/custom/encrypt/users" {
+			t.Fatalf("unexpected call: %v", last)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package silent
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// MarshalXML implements the xml.Marshaler interface. It encrypts the value using the bound crypter,
+// and writes it as an element encoded as selected by [WithJSONEncoding], base64 by default.
+// Empty values are written as empty elements.
+//
+// Unlike [EncryptedValueFactory.MarshalText], it never uses the '#'-prefixed form,
+// since XML can't represent all valid UTF-8 strings, e.g. those with control characters.
+func (v EncryptedValueFactory[T]) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(v) == 0 {
+		return e.EncodeElement("", start)
+	}
+
+	crypter, err := getCrypterFor[T]()
+	if err != nil {
+		return err
+	}
+
+	encData, err := crypter.Encrypt(v)
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeElement(string(crypter.jsonText(encData)), start)
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface. It decrypts the value using the bound crypter.
+// Surrounding whitespace is ignored. For compatibility, the text form produced by
+// [EncryptedValueFactory.MarshalText] is accepted as well.
+func (v *EncryptedValueFactory[T]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return err
+	}
+
+	return v.UnmarshalText([]byte(strings.TrimSpace(text)))
+}
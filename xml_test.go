@@ -0,0 +1,97 @@
+package silent
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestEncryptedValueXML(t *testing.T) {
+	c1 := MultiKeyCrypter{}
+	c1.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+
+	c1bypass := MultiKeyCrypter{}
+	c1bypass.AddKey(0x1, DecodeBase64(t, "Qpk1tvmH8nAljiKyyDaGJXRH82ZjWtEX+2PR50sB5WU="))
+	c1bypass.Bypass = true
+
+	type dummy1 struct{}
+	type EncryptedValue1 = EncryptedValueFactory[dummy1]
+	BindCrypterTo[EncryptedValue1](&c1)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue1]() })
+
+	type dummy2 struct{}
+	type EncryptedValue2 = EncryptedValueFactory[dummy2]
+	BindCrypterTo[EncryptedValue2](&c1bypass)
+	t.Cleanup(func() { UnbindCrypter[EncryptedValue2]() })
+
+	type user struct {
+		XMLName  xml.Name        `xml:"user"`
+		Username string          `xml:"username"`
+		Token    EncryptedValue1 `xml:"token"`
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, text := range texts {
+			orig := user{Username: "john", Token: EncryptedValue1(text)}
+
+			enc, err := xml.Marshal(orig)
+			RequireNoError(t, err)
+
+			if len(text) > 0 && bytes.Contains(enc, text) {
+				t.Fatalf("encoded xml contains plaintext")
+			}
+
+			var dec user
+			RequireNoError(t, xml.Unmarshal(enc, &dec))
+			RequireEqual(t, dec.Username, orig.Username)
+			RequireEqual(t, string(dec.Token), string(orig.Token))
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		enc, err := xml.Marshal(user{Username: "john"})
+		RequireNoError(t, err)
+		RequireEqual(t, string(enc), "<user><username>john</username><token></token></user>")
+
+		dec := user{Token: EncryptedValue1("stale")}
+		RequireNoError(t, xml.Unmarshal(enc, &dec))
+		RequireTrue(t, dec.Token == nil)
+	})
+
+	t.Run("bypass", func(t *testing.T) {
+		// control characters can't be represented in XML as is
+		type record struct {
+			Data EncryptedValue2 `xml:"data"`
+		}
+
+		orig := record{Data: EncryptedValue2("line1\x00\x01line2")}
+		enc, err := xml.MarshalIndent(orig, "", "  ")
+		RequireNoError(t, err)
+
+		var dec record
+		RequireNoError(t, xml.Unmarshal(enc, &dec))
+		RequireEqual(t, string(dec.Data), string(orig.Data))
+	})
+
+	t.Run("text form", func(t *testing.T) {
+		text, err := EncryptedValue1("Hello, World!").MarshalText()
+		RequireNoError(t, err)
+
+		var buf bytes.Buffer
+		RequireNoError(t, xml.EscapeText(&buf, text))
+
+		var dec user
+		RequireNoError(t, xml.Unmarshal([]byte("<user><token>\n  "+buf.String()+"\n</token></user>"), &dec))
+		RequireEqual(t, string(dec.Token), "Hello, World!")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		enc, err := xml.Marshal(user{Token: EncryptedValue1("Hello, World!")})
+		RequireNoError(t, err)
+
+		enc = bytes.Replace(enc, []byte("</token>"), []byte("AA</token>"), 1)
+
+		var dec user
+		RequireError(t, xml.Unmarshal(enc, &dec))
+	})
+}